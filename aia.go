@@ -0,0 +1,133 @@
+package sct
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// aiaFetchTimeout bounds each download of an issuer from an AIA URL.
+const aiaFetchTimeout = 10 * time.Second
+
+// fetchAIAIssuer downloads leaf's issuer from the caIssuers URLs of its authority information
+// access extension, trying each in turn until ctx is done. It returns nil if
+// Options.FetchAIAIssuers is unset or no URL yields leaf's issuer.
+//
+// Downloaded issuers are cached by URL, apart from the issuer pool: a server controls the AIA
+// URLs of its certificate, so what they serve must not replace the issuers given to AddIssuer.
+func (c *checker) fetchAIAIssuer(ctx context.Context, leaf *ctx509.Certificate) *ctx509.Certificate {
+	if !c.opts.FetchAIAIssuers {
+		return nil
+	}
+
+	for _, u := range leaf.IssuingCertificateURL {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		s := c.shared()
+		s.issuersMu.RLock()
+		issuer, ok := s.fetchedIssuers[u]
+		s.issuersMu.RUnlock()
+		if !ok {
+			var err error
+			if issuer, err = c.fetchIssuer(ctx, u); err != nil {
+				continue
+			}
+			s.issuersMu.Lock()
+			if s.fetchedIssuers == nil {
+				s.fetchedIssuers = make(map[string]*ctx509.Certificate)
+			}
+			s.fetchedIssuers[u] = issuer
+			s.issuersMu.Unlock()
+		}
+		if checkIssuer(leaf, issuer) == nil {
+			return issuer
+		}
+	}
+	return nil
+}
+
+// fetchIssuer downloads and parses the DER or PEM certificate at rawURL.
+func (c *checker) fetchIssuer(ctx context.Context, rawURL string) (*ctx509.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiaFetchTimeout)
+	defer cancel()
+
+	data, err := c.fetchAIA(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCertificateSize("AIA issuer certificate", data); err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+		data = block.Bytes
+	}
+	return parseCertificate(data)
+}
+
+// fetchAIA returns the contents of an AIA URL. Besides http and https, file and ldap URLs are
+// supported with Options.AIAExtendedSchemes.
+func (c *checker) fetchAIA(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "file":
+		if !c.opts.AIAExtendedSchemes {
+			return nil, fmt.Errorf("file AIA URL %s not allowed", rawURL)
+		}
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return c.readCertificate(f)
+	case "ldap", "ldaps":
+		if !c.opts.AIAExtendedSchemes {
+			return nil, fmt.Errorf("LDAP AIA URL %s not allowed", rawURL)
+		}
+		if c.opts.LDAPFetch == nil {
+			return nil, errors.New("no LDAP client configured, see Options.LDAPFetch")
+		}
+		return c.opts.LDAPFetch(ctx, rawURL)
+	default:
+		return nil, fmt.Errorf("unsupported AIA URL scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(c.opts.userAgent()).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AIA URL %s returned %s", rawURL, resp.Status)
+	}
+	return c.readCertificate(resp.Body)
+}
+
+// readCertificate reads a certificate from r, stopping one byte past Options.MaxCertificateBytes
+// so that oversized certificates are reported as such rather than read in full.
+func (c *checker) readCertificate(r io.Reader) ([]byte, error) {
+	limit := limitOrDefault(c.opts.MaxCertificateBytes, DefaultMaxCertificateBytes)
+	if limit < 0 {
+		return ioutil.ReadAll(r)
+	}
+	return ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+}
@@ -0,0 +1,182 @@
+package sct
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist2"
+	ctx509util "github.com/google/certificate-transparency-go/x509util"
+)
+
+// Apple CT policy parameters, see https://support.apple.com/en-us/103214.
+const (
+	// AppleLogListURL is Apple's list of logs trusted under its CT policy. It is not signed.
+	AppleLogListURL = "https://valid.apple.com/ct/log_list/current_log_list.json"
+
+	// appleShortLifetime is the longest certificate lifetime needing appleShortLifetimeSCTs embedded SCTs.
+	appleShortLifetime     = 180 * 24 * time.Hour
+	appleShortLifetimeSCTs = 2
+	appleLongLifetimeSCTs  = 3
+	// appleDeliveredSCTs is the number of SCTs needed when delivered via TLS or OCSP.
+	appleDeliveredSCTs = 2
+	appleMinOperators  = 2
+)
+
+// FetchAppleLogList fetches Apple's log list, to build a checker for AppleComplianceReport.
+// Like the default log list, only logs that are qualified, usable or read-only are kept,
+// along with retired logs, whose SCTs issued before retirement still count under Apple's policy.
+func FetchAppleLogList() (*loglist2.LogList, error) {
+	data, err := ctx509util.ReadFileOrURL(AppleLogListURL, newHTTPClient(defaultUserAgent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log list %s: %v", AppleLogListURL, err)
+	}
+
+	ll, err := loglist2.NewFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := ll.SelectByStatus(append(qualifiedLogs, loglist2.RetiredLogStatus))
+	return &selected, nil
+}
+
+// AppleComplianceReport evaluates the connection state against Apple's CT policy using the
+// default checker. See (*checker).AppleComplianceReport.
+func AppleComplianceReport(state *tls.ConnectionState) *Report {
+	return GetDefaultChecker().AppleComplianceReport(state)
+}
+
+// AppleComplianceReport evaluates the connection state against Apple's CT policy, parallel to
+// ComplianceReport for Chrome's. The checker should use Apple's log list, see FetchAppleLogList.
+//
+// Apple's rules, as implemented here:
+//   - embedded SCTs: 2 for certificates valid for at most 180 days, 3 for longer ones, counting SCTs
+//     from logs that are qualified, usable or read-only, or were retired after issuing the SCT;
+//   - SCTs delivered via TLS or OCSP: 2, counting SCTs from logs that are qualified, usable or
+//     read-only at the time of the check;
+//   - the counted SCTs are from at least 2 distinct log operators;
+//   - each log counts once, however many of its SCTs are presented.
+//
+// Unlike Chrome's policy, inclusion proofs are not required.
+func (c *checker) AppleComplianceReport(state *tls.ConnectionState) *Report {
+	report := &Report{}
+
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		report.addViolation("%v", err)
+		return report
+	}
+	report.Result = result
+	if result.NotApplicable {
+		return report
+	}
+
+	chain, err := BuildCertificateChain(state.PeerCertificates[:1])
+	if err != nil {
+		report.addViolation("%v", err)
+		return report
+	}
+	leaf := chain[0]
+
+	embeddedLogs := make(map[string]bool)
+	deliveredLogs := make(map[string]bool)
+	embeddedOperators := make(map[string]bool)
+	deliveredOperators := make(map[string]bool)
+	for _, s := range result.SCTs {
+		if !s.Valid() {
+			continue
+		}
+		ctLog := c.logByHexID(s.LogID)
+		if ctLog == nil {
+			continue
+		}
+
+		if s.Source == SourceEmbedded {
+			if appleCountsEmbedded(ctLog, s.Timestamp) {
+				embeddedLogs[s.LogID] = true
+				embeddedOperators[c.operatorAtIssuance(s)] = true
+			}
+		} else if appleCountsDelivered(ctLog) {
+			deliveredLogs[s.LogID] = true
+			deliveredOperators[c.operatorAtIssuance(s)] = true
+		}
+	}
+
+	embeddedNeeded := appleLongLifetimeSCTs
+	if leaf.NotAfter.Sub(leaf.NotBefore) <= appleShortLifetime {
+		embeddedNeeded = appleShortLifetimeSCTs
+	}
+	embeddedOK := len(embeddedLogs) >= embeddedNeeded
+	deliveredOK := len(deliveredLogs) >= appleDeliveredSCTs
+
+	// Diversity is required of a set of SCTs meeting its count.
+	switch {
+	case embeddedOK && len(embeddedOperators) >= appleMinOperators,
+		deliveredOK && len(deliveredOperators) >= appleMinOperators:
+		// Compliant.
+	case embeddedOK || deliveredOK:
+		n := 0
+		if embeddedOK {
+			n = len(embeddedOperators)
+		}
+		if deliveredOK && len(deliveredOperators) > n {
+			n = len(deliveredOperators)
+		}
+		report.addViolation("insufficient log operator diversity: counted SCTs from %d operator(s), need %d",
+			n, appleMinOperators)
+	default:
+		report.addViolation("too few valid SCTs: %d embedded (need %d), %d delivered via TLS or OCSP (need %d)",
+			len(embeddedLogs), embeddedNeeded, len(deliveredLogs), appleDeliveredSCTs)
+
+		operators := make(map[string]bool)
+		for op := range embeddedOperators {
+			operators[op] = true
+		}
+		for op := range deliveredOperators {
+			operators[op] = true
+		}
+		if len(operators) < appleMinOperators {
+			report.addViolation("insufficient log operator diversity: counted SCTs from %d operator(s), need %d",
+				len(operators), appleMinOperators)
+		}
+	}
+
+	return report
+}
+
+// appleCountsEmbedded returns true if an embedded SCT issued by ctLog at t counts under Apple's policy.
+func appleCountsEmbedded(ctLog *loglist2.Log, t time.Time) bool {
+	if ctLog.State == nil {
+		return false
+	}
+	if ctLog.State.Retired != nil {
+		return t.Before(ctLog.State.Retired.Timestamp)
+	}
+	return appleCountsDelivered(ctLog)
+}
+
+// appleCountsDelivered returns true if an SCT delivered via TLS or OCSP from ctLog counts under
+// Apple's policy.
+func appleCountsDelivered(ctLog *loglist2.Log) bool {
+	switch ctLog.State.LogStatus() {
+	case loglist2.QualifiedLogStatus, loglist2.UsableLogStatus, loglist2.ReadOnlyLogStatus:
+		return true
+	}
+	return false
+}
+
+// logByHexID returns the log with the given hex-encoded KeyID, or nil if it is not in the log list.
+func (c *checker) logByHexID(id string) *loglist2.Log {
+	keyID, err := hex.DecodeString(id)
+	if err != nil || len(keyID) != sha256.Size {
+		return nil
+	}
+
+	var keyHash [sha256.Size]byte
+	copy(keyHash[:], keyID)
+	ctLog, _ := findLogByKeyHash(c.logList(), keyHash)
+	return ctLog
+}
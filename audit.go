@@ -0,0 +1,183 @@
+package sct
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist2"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	ctx509util "github.com/google/certificate-transparency-go/x509util"
+)
+
+// AuditReport is the outcome of AuditCertificate: everything needed to check each embedded SCT
+// again independently, from its log's key down to the inclusion proof.
+type AuditReport struct {
+	// AuditedAt is when the audit started.
+	AuditedAt time.Time
+	SCTs      []*SCTAudit
+}
+
+// Complete returns true if every embedded SCT passed each step of the audit.
+func (r *AuditReport) Complete() bool {
+	for _, a := range r.SCTs {
+		if a.Err != nil {
+			return false
+		}
+	}
+	return len(r.SCTs) > 0
+}
+
+// SCTAudit is the audit trail of one embedded SCT. The steps run in the order of the fields, and
+// the audit stops at the first failing step: the fields of later steps are then left unset.
+type SCTAudit struct {
+	// Raw is the serialized SCT as embedded in the certificate.
+	Raw []byte
+	// SCT is the decoded SCT.
+	SCT *ct.SignedCertificateTimestamp
+	// Log is the issuing log, as found in the log list, and Operator the name of its operator.
+	Log      *loglist2.Log
+	Operator string
+	// MerkleLeaf is the log entry the SCT covers, with its timestamp set, see embeddedMerkleLeaves.
+	MerkleLeaf *ct.MerkleTreeLeaf
+	// SignatureVerified is true if the SCT's signature over MerkleLeaf verifies with the log's key.
+	SignatureVerified bool
+	// STH is the log's current signed tree head, fetched for the audit.
+	STH *ct.SignedTreeHead
+	// STHVerified is true if STH's signature verifies with the log's key.
+	STHVerified bool
+	// LeafHash is the Merkle tree hash of MerkleLeaf, and LeafIndex and AuditPath the log's proof
+	// of its inclusion in the tree of STH.
+	LeafHash  []byte
+	LeafIndex int64
+	AuditPath [][]byte
+	// InclusionVerified is true if the proof leads from LeafHash to the root hash of STH.
+	InclusionVerified bool
+	// Err is the failure of the step the audit stopped at, or nil if every step passed.
+	Err error
+}
+
+// AuditCertificate audits the SCTs embedded in leaf using the default checker.
+// See (*checker).AuditCertificate.
+func AuditCertificate(ctx context.Context, leaf, issuer *ctx509.Certificate) (*AuditReport, error) {
+	return GetDefaultChecker().AuditCertificate(ctx, leaf, issuer)
+}
+
+// AuditCertificate verifies each SCT embedded in leaf, issued by issuer, as rigorously as
+// possible, and records every step: it resolves the issuing log, verifies the SCT's signature,
+// fetches the log's current STH and verifies its signature, then fetches the inclusion proof for
+// the SCT's entry against that STH and verifies it. Unlike CheckConnectionState, no SCT is
+// accepted on the strength of its signature alone, and options that reject SCTs by policy, such
+// as OnlyUsableLogs, are not applied: the report holds the facts for the caller to judge.
+//
+// An error is returned if leaf has no SCTs or was not issued by issuer. Once ctx is done, the
+// audits completed so far are returned along with ctx.Err().
+func (c *checker) AuditCertificate(ctx context.Context, leaf, issuer *ctx509.Certificate) (*AuditReport, error) {
+	if leaf == nil || issuer == nil {
+		return nil, errors.New("no leaf and issuer certificates to audit")
+	}
+	if len(leaf.SCTList.SCTList) == 0 {
+		return nil, errors.New("no SCTs in leaf certificate")
+	}
+	if err := checkIssuer(leaf, issuer); err != nil {
+		return nil, err
+	}
+	if err := c.checkIssuerSignature(leaf, issuer); err != nil {
+		return nil, err
+	}
+	primary, alt, err := embeddedMerkleLeaves(leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{AuditedAt: c.opts.now()}
+	for i := range leaf.SCTList.SCTList {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		a := c.auditSCT(ctx, &leaf.SCTList.SCTList[i], leaf, primary, alt)
+		if err := ctx.Err(); err != nil {
+			// The audit was interrupted, so its outcome is unreliable.
+			return report, err
+		}
+		report.SCTs = append(report.SCTs, a)
+	}
+	return report, nil
+}
+
+// auditSCT audits an SCT embedded in leaf, which may cover either Merkle leaf of
+// embeddedMerkleLeaves, primary or alt.
+func (c *checker) auditSCT(ctx context.Context, serialized *ctx509.SerializedSCT, leaf *ctx509.Certificate, primary, alt *ct.MerkleTreeLeaf) *SCTAudit {
+	a := &SCTAudit{Raw: serialized.Val}
+
+	sct, err := ctx509util.ExtractSCT(serialized)
+	if err != nil {
+		a.Err = err
+		return a
+	}
+	a.SCT = sct
+
+	ctLog, operator := findLogByKeyHash(c.logList(), sct.LogID.KeyID)
+	if ctLog == nil {
+		a.Err = fmt.Errorf("%w with KeyID %x (%s)", errUnknownLog, sct.LogID.KeyID, c.logListDiagnostics())
+		return a
+	}
+	a.Log, a.Operator = ctLog, operator.Name
+
+	logInfo, err := c.logInfoForLog(ctLog)
+	if err != nil {
+		a.Err = err
+		return a
+	}
+
+	merkleLeaf := primary
+	err = logInfo.VerifySCTSignature(*sct, *primary)
+	if err != nil && alt != nil && logInfo.VerifySCTSignature(*sct, *alt) == nil {
+		merkleLeaf, err = alt, nil
+	}
+	entry := *merkleLeaf.TimestampedEntry
+	entry.Timestamp = sct.Timestamp
+	a.MerkleLeaf = &ct.MerkleTreeLeaf{Version: merkleLeaf.Version, LeafType: merkleLeaf.LeafType, TimestampedEntry: &entry}
+	if err != nil {
+		a.Err = diagnoseEmbeddedSignature(signatureError{err}, leaf, primary)
+		return a
+	}
+	a.SignatureVerified = true
+
+	sth, err := fetchSTHUnverified(ctx, logInfo.Client)
+	if err != nil {
+		a.Err = fmt.Errorf("failed to get STH for log %q: %v", ctLog.Description, err)
+		return a
+	}
+	a.STH = sth
+	if err := logInfo.Verifier.VerifySTHSignature(*sth); err != nil {
+		a.Err = fmt.Errorf("invalid STH from log %q: %v", ctLog.Description, err)
+		return a
+	}
+	a.STHVerified = true
+	logInfo.SetSTH(sth)
+	if err := c.verifyPinnedConsistency(ctx, logInfo, sth); err != nil {
+		a.Err = err
+		return a
+	}
+
+	verifier := c.opts.inclusionVerifier()
+	a.LeafHash, err = hashMerkleLeaf(verifier, a.MerkleLeaf)
+	if err != nil {
+		a.Err = err
+		return a
+	}
+	a.LeafIndex, a.AuditPath, err = c.fetchInclusionProof(ctx, logInfo, verifier, a.LeafHash, sct.Timestamp, sth.TreeSize)
+	if err != nil {
+		a.Err = fmt.Errorf("log %q: %v", ctLog.Description, err)
+		return a
+	}
+	if err := verifier.VerifyInclusionProof(a.LeafIndex, int64(sth.TreeSize), a.AuditPath, sth.SHA256RootHash[:], a.LeafHash); err != nil {
+		a.Err = fmt.Errorf("failed to verify inclusion proof in log %q at size %d: %v", ctLog.Description, sth.TreeSize, err)
+		return a
+	}
+	a.InclusionVerified = true
+	return a
+}
@@ -0,0 +1,101 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+func TestAuditCertificate(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	unknown := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	precert := ca.precertLeaf(t, tmpl)
+	sct := l.sign(t, precert, time.Now().Add(-time.Minute))
+	chain := mustBuildChain(t, ca.issue(t, tmpl, sct, unknown.sign(t, precert, time.Now())), ca.cert)
+
+	// A two-entry tree: the SCT's entry, then another one.
+	entry := *precert
+	timestamped := *precert.TimestampedEntry
+	timestamped.Timestamp = sct.Timestamp
+	entry.TimestampedEntry = &timestamped
+	leafInput, err := cttls.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(leafInput)
+	sibling := sha256.Sum256([]byte("another entry"))
+	root := rfc6962.DefaultHasher.HashChildren(leafHash, sibling[:])
+
+	sthSigner := l
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			sth := sthSigner.signSTH(t, 2, root)
+			sig, err := cttls.Marshal(sth.TreeHeadSignature)
+			if err != nil {
+				t.Error(err)
+			}
+			rsp = ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig}
+		case "/ct/v1/get-proof-by-hash":
+			rsp = ct.GetProofByHashResponse{LeafIndex: 0, AuditPath: [][]byte{sibling[:]}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	c := newTestChecker(l)
+	report, err := c.AuditCertificate(context.Background(), chain[0], chain[1])
+	if err != nil {
+		t.Fatalf("AuditCertificate: %v", err)
+	}
+	if len(report.SCTs) != 2 || report.Complete() {
+		t.Fatalf("got %d audited SCTs, complete %v; want 2, incomplete", len(report.SCTs), report.Complete())
+	}
+
+	a := report.SCTs[0]
+	if a.Err != nil || !a.SignatureVerified || !a.STHVerified || !a.InclusionVerified {
+		t.Errorf("audit of the logged SCT: signature %v, STH %v, inclusion %v, error %v; want all verified",
+			a.SignatureVerified, a.STHVerified, a.InclusionVerified, a.Err)
+	}
+	if a.Log != l.log || a.STH == nil || a.STH.TreeSize != 2 || !bytes.Equal(a.LeafHash, leafHash) || a.MerkleLeaf.TimestampedEntry.Timestamp != sct.Timestamp {
+		t.Errorf("audit trail %+v does not record the log, STH and entry", a)
+	}
+	if err := c.VerifyInclusionWithProof(a.SCT, a.MerkleLeaf, &ct.GetProofByHashResponse{LeafIndex: a.LeafIndex, AuditPath: a.AuditPath}, a.STH); err != nil {
+		t.Errorf("recorded trail does not verify independently: %v", err)
+	}
+
+	if a := report.SCTs[1]; a.Err == nil || a.Log != nil || a.SignatureVerified {
+		t.Errorf("audit of the SCT from an unknown log = %+v, want it stopped at the log lookup", a)
+	}
+
+	// The log serves an STH signed by another key.
+	sthSigner = unknown
+	report, err = c.AuditCertificate(context.Background(), chain[0], chain[1])
+	if err != nil {
+		t.Fatalf("AuditCertificate: %v", err)
+	}
+	if a := report.SCTs[0]; a.Err == nil || !a.SignatureVerified || a.STH == nil || a.STHVerified || a.InclusionVerified {
+		t.Errorf("audit with a bad STH: signature %v, STH %v verified %v, inclusion %v, error %v; want it stopped at the STH",
+			a.SignatureVerified, a.STH != nil, a.STHVerified, a.InclusionVerified, a.Err)
+	}
+
+	if _, err := c.AuditCertificate(context.Background(), chain[0], mustBuildChain(t, newTestCA(t).cert)[0]); err == nil {
+		t.Error("AuditCertificate succeeded with the wrong issuer")
+	}
+}
@@ -0,0 +1,160 @@
+package sct
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// EmbeddedPair is a DER-encoded leaf certificate and the DER-encoded certificate of its issuer,
+// for CheckEmbeddedBatch.
+type EmbeddedPair struct {
+	Leaf, Issuer []byte
+}
+
+// BatchResult is the outcome of one check of a batch: Err is set if it could not be checked at
+// all, and Result otherwise. For CheckConnectionStates, Err is also set alongside Result if the
+// result could not be saved to Options.ResultStore.
+type BatchResult struct {
+	Result *Result
+	Err    error
+}
+
+// CheckEmbeddedBatch verifies the embedded SCTs of many leaves using the default checker.
+// See (*checker).CheckEmbeddedBatch.
+func CheckEmbeddedBatch(pairs []EmbeddedPair) []BatchResult {
+	return GetDefaultChecker().CheckEmbeddedBatch(pairs)
+}
+
+// CheckEmbeddedBatch verifies the SCTs embedded in the leaf of each pair, as CheckCertFile does,
+// and returns the outcomes in the order of pairs. It is meant for re-verifying large corpora, in
+// which a few issuers sign most leaves: each distinct issuer is parsed once, keyed by the SHA-256
+// fingerprint of its DER encoding, rather than once per leaf.
+func (c *checker) CheckEmbeddedBatch(pairs []EmbeddedPair) []BatchResult {
+	type parsedIssuer struct {
+		cert *ctx509.Certificate
+		err  error
+	}
+	issuers := make(map[[sha256.Size]byte]parsedIssuer)
+
+	progress := c.newProgressTracker()
+	defer progress.finish()
+
+	results := make([]BatchResult, len(pairs))
+	for i, pair := range pairs {
+		if err := c.checkCertificateSize("leaf certificate", pair.Leaf); err != nil {
+			results[i].Err = err
+			progress.record(nil)
+			continue
+		}
+		if err := c.checkCertificateSize("issuer certificate", pair.Issuer); err != nil {
+			results[i].Err = err
+			progress.record(nil)
+			continue
+		}
+
+		fingerprint := sha256.Sum256(pair.Issuer)
+		issuer, ok := issuers[fingerprint]
+		if !ok {
+			issuer.cert, issuer.err = parseCertificate(pair.Issuer)
+			if issuer.err != nil {
+				issuer.err = fmt.Errorf("failed to parse issuer certificate: %v", issuer.err)
+			}
+			issuers[fingerprint] = issuer
+		}
+		if issuer.err != nil {
+			results[i].Err = issuer.err
+			progress.record(nil)
+			continue
+		}
+
+		leaf, err := parseCertificate(pair.Leaf)
+		if err != nil {
+			results[i].Err = fmt.Errorf("failed to parse leaf certificate: %v", err)
+			progress.record(nil)
+			continue
+		}
+		results[i].Result, results[i].Err = c.checkEmbedded(leaf, issuer.cert)
+		progress.record(results[i].Result)
+	}
+	return results
+}
+
+// CheckConnectionStates verifies the SCTs of many connections using the default checker.
+// See (*checker).CheckConnectionStates.
+func CheckConnectionStates(ctx context.Context, states map[string]*tls.ConnectionState) map[string]BatchResult {
+	return GetDefaultChecker().CheckConnectionStates(ctx, states)
+}
+
+// CheckConnectionStates verifies the SCTs of each connection state, as
+// CheckConnectionStateDetailedContext does, and returns the outcomes by the same keys. It is meant
+// for the connections a scanner made to one address with different SNI values, keyed by server
+// name: log clients and known issuers are shared across them, and virtual hosts presenting the
+// same certificates, SCTs and OCSP response are verified once and share the same Result. Each
+// result is saved to Options.ResultStore, if set, under its key.
+func (c *checker) CheckConnectionStates(ctx context.Context, states map[string]*tls.ConnectionState) map[string]BatchResult {
+	progress := c.newProgressTracker()
+	defer progress.finish()
+
+	results := make(map[string]BatchResult, len(states))
+	verified := make(map[[sha256.Size]byte]BatchResult)
+	for name, state := range states {
+		if state == nil {
+			results[name] = BatchResult{Err: errors.New("no TLS connection state")}
+			progress.record(nil)
+			continue
+		}
+
+		key := connectionStateKey(state)
+		res, ok := verified[key]
+		if !ok {
+			res.Result, res.Err = c.CheckConnectionStateDetailedContext(ctx, state)
+			// Interrupted checks are not reused: their results are partial.
+			if ctx.Err() == nil {
+				verified[key] = res
+			}
+		}
+		if res.Result != nil && c.opts.ResultStore != nil {
+			if err := c.opts.ResultStore.Save(name, res.Result); err != nil && res.Err == nil {
+				res.Err = err
+			}
+		}
+		results[name] = res
+		progress.record(res.Result)
+	}
+	return results
+}
+
+// connectionStateKey returns a hash of the verified parts of state: its certificates, TLS SCTs
+// and OCSP response.
+func connectionStateKey(state *tls.ConnectionState) [sha256.Size]byte {
+	h := sha256.New()
+	writeLength := func(n int) {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(n))
+		h.Write(length[:])
+	}
+	write := func(data []byte) {
+		writeLength(len(data))
+		h.Write(data)
+	}
+
+	writeLength(len(state.PeerCertificates))
+	for _, cert := range state.PeerCertificates {
+		write(cert.Raw)
+	}
+	writeLength(len(state.SignedCertificateTimestamps))
+	for _, sct := range state.SignedCertificateTimestamps {
+		write(sct)
+	}
+	write(state.OCSPResponse)
+
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
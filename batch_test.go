@@ -0,0 +1,185 @@
+package sct
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"testing"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+func TestCheckEmbeddedBatch(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+
+	results := c.CheckEmbeddedBatch([]EmbeddedPair{
+		{Leaf: leaf.Raw, Issuer: ca.cert.Raw},
+		{Leaf: leaf.Raw, Issuer: other.cert.Raw},
+		{Leaf: []byte("garbage"), Issuer: ca.cert.Raw},
+		{Leaf: leaf.Raw, Issuer: []byte("garbage")},
+		{Leaf: leaf.Raw, Issuer: ca.cert.Raw},
+	})
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+	for _, i := range []int{0, 4} {
+		if results[i].Err != nil || results[i].Result.ValidCount() != 1 {
+			t.Errorf("pair %d: got %+v, want 1 valid SCT", i, results[i])
+		}
+	}
+	for _, i := range []int{1, 2, 3} {
+		if results[i].Err == nil {
+			t.Errorf("pair %d: checked without error, want an error", i)
+		}
+	}
+}
+
+// benchmarkPairs returns n leaves with an embedded SCT, all issued by one CA.
+func benchmarkPairs(b *testing.B, n int) (*checker, []EmbeddedPair) {
+	l := newTestLog(b, "Test Log")
+	ca := newTestCA(b)
+	pairs := make([]EmbeddedPair, n)
+	for i := range pairs {
+		pairs[i] = EmbeddedPair{Leaf: ca.issueWithEmbeddedSCTs(b, leafTemplate(), l).Raw, Issuer: ca.cert.Raw}
+	}
+	return newTestChecker(l), pairs
+}
+
+// BenchmarkCheckEmbeddedBatch checks 100 leaves sharing an issuer, which is parsed once.
+// Compare with BenchmarkCheckEmbeddedPerLeaf, which parses it for every leaf.
+func BenchmarkCheckEmbeddedBatch(b *testing.B) {
+	c, pairs := benchmarkPairs(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, res := range c.CheckEmbeddedBatch(pairs) {
+			if res.Err != nil {
+				b.Fatal(res.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkCheckEmbeddedPerLeaf(b *testing.B) {
+	c, pairs := benchmarkPairs(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pair := range pairs {
+			leaf, err := ctx509.ParseCertificate(pair.Leaf)
+			if err != nil {
+				b.Fatal(err)
+			}
+			issuer, err := ctx509.ParseCertificate(pair.Issuer)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := c.checkEmbedded(leaf, issuer); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestCheckConnectionStates(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	shared := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	other := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+
+	results := c.CheckConnectionStates(context.Background(), map[string]*tls.ConnectionState{
+		"a.example.com": {PeerCertificates: []*x509.Certificate{shared, ca.cert}},
+		"b.example.com": {PeerCertificates: []*x509.Certificate{shared, ca.cert}},
+		"c.example.com": {PeerCertificates: []*x509.Certificate{other, ca.cert}},
+		"d.example.com": nil,
+	})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, name := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		if res := results[name]; res.Err != nil || res.Result.ValidCount() != 1 {
+			t.Errorf("%s: got %+v, want 1 valid SCT", name, res)
+		}
+	}
+	if results["a.example.com"].Result != results["b.example.com"].Result {
+		t.Error("identical connection states verified twice")
+	}
+	if results["a.example.com"].Result == results["c.example.com"].Result {
+		t.Error("different connection states share a result")
+	}
+	if results["d.example.com"].Err == nil {
+		t.Error("nil connection state checked without error")
+	}
+}
+
+func TestBatchProgress(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	valid := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	unlogged := ca.issue(t, leafTemplate())
+
+	var reports []Progress
+	c := newTestChecker(l)
+	c.opts.OnProgress = func(p Progress) { reports = append(reports, p) }
+	c.opts.ProgressInterval = 2
+
+	c.CheckEmbeddedBatch([]EmbeddedPair{
+		{Leaf: valid.Raw, Issuer: ca.cert.Raw},
+		{Leaf: unlogged.Raw, Issuer: ca.cert.Raw},
+		{Leaf: []byte("garbage"), Issuer: ca.cert.Raw},
+		{Leaf: valid.Raw, Issuer: ca.cert.Raw},
+		{Leaf: valid.Raw, Issuer: ca.cert.Raw},
+	})
+
+	want := []Progress{
+		{Done: 2, Passed: 1, Failed: 1},
+		{Done: 4, Passed: 2, Failed: 1, Errors: 1},
+		{Done: 5, Passed: 3, Failed: 1, Errors: 1},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("got reports %+v, want %+v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("report %d = %+v, want %+v", i, reports[i], want[i])
+		}
+	}
+}
+
+func TestProgressTrackerConcurrent(t *testing.T) {
+	var last Progress
+	calls := 0
+	c := newTestChecker()
+	c.opts.OnProgress = func(p Progress) {
+		// Reports are serialized, so this needs no lock.
+		calls++
+		last = p
+	}
+	c.opts.ProgressInterval = 10
+	progress := c.newProgressTracker()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				progress.record(nil)
+			}
+		}()
+	}
+	wg.Wait()
+	progress.finish()
+
+	if calls != 20 || last != (Progress{Done: 200, Errors: 200}) {
+		t.Errorf("got %d reports, last %+v; want 20, ending with 200 errors", calls, last)
+	}
+}
@@ -0,0 +1,82 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// CheckCapturedHandshake verifies the SCTs of a handshake reconstructed from a capture using the
+// default checker. See (*checker).CheckCapturedHandshake.
+func CheckCapturedHandshake(sctExtension []byte, certs [][]byte) (*Result, error) {
+	return GetDefaultChecker().CheckCapturedHandshake(sctExtension, certs)
+}
+
+// CheckCapturedHandshake verifies the SCTs of a TLS handshake reconstructed by a passive monitor,
+// e.g. from a capture decrypted with a key log file, as CheckConnectionStateDetailed would for a
+// live connection.
+//
+// certs holds the DER certificates of the server's Certificate message, leaf first.
+//
+// sctExtension is the extension_data of the signed_certificate_timestamp extension (RFC 6962 s3.3),
+// from the ServerHello in TLS 1.2 or the leaf's CertificateEntry in TLS 1.3, without the extension
+// type and length. It is a SignedCertificateTimestampList: a 2-byte big-endian length of the
+// remaining data, followed by each SCT as a 2-byte big-endian length and the TLS-encoded SCT.
+// A nil sctExtension means the extension was absent.
+func (c *checker) CheckCapturedHandshake(sctExtension []byte, certs [][]byte) (*Result, error) {
+	state := &tls.ConnectionState{}
+
+	if err := c.checkSCTListSize("TLS extension SCT list", len(sctExtension)); err != nil {
+		return nil, err
+	}
+
+	for i, der := range certs {
+		if err := c.checkCertificateSize(fmt.Sprintf("certificate %d", i), der); err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %v", i, err)
+		}
+		state.PeerCertificates = append(state.PeerCertificates, cert)
+	}
+
+	scts, err := parseSCTList(sctExtension)
+	if err != nil {
+		return nil, err
+	}
+	state.SignedCertificateTimestamps = scts
+
+	return c.CheckConnectionStateDetailed(state)
+}
+
+// parseSCTList splits a TLS-encoded SignedCertificateTimestampList into its SCTs.
+// An empty list, which RFC 6962 forbids but a capture may contain, yields a non-nil empty slice.
+func parseSCTList(data []byte) ([][]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	if len(data) == 2 && data[0] == 0 && data[1] == 0 {
+		return [][]byte{}, nil
+	}
+
+	var list ctx509.SignedCertificateTimestampList
+	rest, err := cttls.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SCT list: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after SCT list")
+	}
+
+	scts := make([][]byte, len(list.SCTList))
+	for i, sct := range list.SCTList {
+		scts[i] = sct.Val
+	}
+
+	return scts, nil
+}
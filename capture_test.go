@@ -0,0 +1,112 @@
+package sct
+
+import (
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+func TestCheckCapturedHandshake(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	list := ctx509.SignedCertificateTimestampList{
+		SCTList: []ctx509.SerializedSCT{{Val: marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))}},
+	}
+	extension, err := cttls.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := [][]byte{leaf.Raw, ca.cert.Raw}
+	c := newTestChecker(l)
+
+	result, err := c.CheckCapturedHandshake(extension, certs)
+	if err != nil {
+		t.Fatalf("CheckCapturedHandshake: %v", err)
+	}
+	if result.ValidCount() != 1 || result.TLSExtension != TLSExtensionPresent {
+		t.Errorf("got %d valid SCTs, extension %v; want 1 valid, present", result.ValidCount(), result.TLSExtension)
+	}
+
+	result, err = c.CheckCapturedHandshake([]byte{0x00, 0x00}, certs)
+	if err != nil {
+		t.Fatalf("CheckCapturedHandshake(empty list): %v", err)
+	}
+	if result.TLSExtension != TLSExtensionEmpty {
+		t.Errorf("extension = %v, want empty", result.TLSExtension)
+	}
+
+	if _, err := c.CheckCapturedHandshake(extension[:len(extension)-1], certs); err == nil {
+		t.Error("CheckCapturedHandshake accepted a truncated extension")
+	}
+}
+
+func TestCheckSCTListBytes(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	chain := mustBuildChain(t, leaf, ca.cert)
+	list := ctx509.SignedCertificateTimestampList{
+		SCTList: []ctx509.SerializedSCT{{Val: marshalSCT(t, l.sign(t, x509Leaf(t, chain), time.Now()))}},
+	}
+	listBytes, err := cttls.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestChecker(l)
+
+	result, err := c.CheckSCTListBytes(listBytes, chain, ct.X509LogEntryType)
+	if err != nil {
+		t.Fatalf("CheckSCTListBytes: %v", err)
+	}
+	if result.ValidCount() != 1 || result.SCTs[0].Source != SourceTLSExtension {
+		t.Errorf("got %d valid SCTs; want 1 valid from the TLS extension", result.ValidCount())
+	}
+
+	// The same SCT checked as if embedded is over the wrong entry and must fail.
+	result, err = c.CheckSCTListBytes(listBytes, chain, ct.PrecertLogEntryType)
+	if err != nil {
+		t.Fatalf("CheckSCTListBytes(precert): %v", err)
+	}
+	if result.ValidCount() != 0 {
+		t.Errorf("got %d valid SCTs as precert entries, want 0", result.ValidCount())
+	}
+
+	if _, err := c.CheckSCTListBytes([]byte{0x00, 0x00}, chain, ct.X509LogEntryType); err == nil {
+		t.Error("CheckSCTListBytes accepted an empty list")
+	}
+	if _, err := c.CheckSCTListBytes(listBytes[:len(listBytes)-1], chain, ct.X509LogEntryType); err == nil {
+		t.Error("CheckSCTListBytes accepted a truncated list")
+	}
+}
+
+func TestSCTFingerprint(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	sct := l.sign(t, merkleLeaf, time.Now())
+	serialized := marshalSCT(t, sct)
+
+	// Decoding and re-encoding the same SCT gives the same fingerprint.
+	if SCTFingerprint(serialized) != SCTFingerprint(append([]byte(nil), serialized...)) {
+		t.Error("fingerprint of an identical SCT differs")
+	}
+
+	other := *sct
+	other.Timestamp++
+	if SCTFingerprint(serialized) == SCTFingerprint(marshalSCT(t, &other)) {
+		t.Error("SCTs with different timestamps share a fingerprint")
+	}
+
+	malformed := serialized[:len(serialized)-1]
+	if SCTFingerprint(malformed) != SCTFingerprint(append([]byte(nil), malformed...)) {
+		t.Error("fingerprint of a malformed SCT is not stable")
+	}
+	if SCTFingerprint(malformed) == SCTFingerprint(serialized) {
+		t.Error("malformed SCT shares the fingerprint of the SCT it was cut from")
+	}
+}
@@ -3,7 +3,10 @@
 package sct
 
 import (
+	"crypto/sha256"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/certificate-transparency-go/asn1"
 	ctx509 "github.com/google/certificate-transparency-go/x509"
@@ -319,4 +322,59 @@ func maxValidationLevel(a, b CertValidationLevel) CertValidationLevel {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
+
+// ShortLivedLifetime is the longest lifetime of a short-lived certificate. Short-lived
+// certificates are usually domain validated, and some CT policies relax their SCT requirements.
+const ShortLivedLifetime = 10 * 24 * time.Hour
+
+// IsShortLived returns true if the certificate's validity period is at most ShortLivedLifetime.
+func IsShortLived(cert *ctx509.Certificate) bool {
+	return cert.NotAfter.Sub(cert.NotBefore) <= ShortLivedLifetime
+}
+
+// ValidationLevelCache memoizes ValidationLevel by certificate SHA-256 fingerprint, for scans
+// that repeatedly see the same leaf. It is opt-in and bounded: once it holds maxEntries results,
+// further certificates are classified without being cached. It is safe for concurrent use.
+type ValidationLevelCache struct {
+	mu         sync.RWMutex
+	maxEntries int
+	levels     map[[sha256.Size]byte]string
+}
+
+// NewValidationLevelCache returns an empty cache holding at most maxEntries results.
+func NewValidationLevelCache(maxEntries int) *ValidationLevelCache {
+	return &ValidationLevelCache{
+		maxEntries: maxEntries,
+		levels:     make(map[[sha256.Size]byte]string),
+	}
+}
+
+// ValidationLevel returns ValidationLevel(cert), computing it only if cert has not been seen before.
+func (c *ValidationLevelCache) ValidationLevel(cert *ctx509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	c.mu.RLock()
+	level, ok := c.levels[fingerprint]
+	c.mu.RUnlock()
+	if ok {
+		return level
+	}
+
+	level = ValidationLevel(cert)
+
+	c.mu.Lock()
+	if len(c.levels) < c.maxEntries {
+		c.levels[fingerprint] = level
+	}
+	c.mu.Unlock()
+
+	return level
+}
+
+// Len returns the number of cached results.
+func (c *ValidationLevelCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.levels)
+}
@@ -0,0 +1,60 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+	"time"
+)
+
+func TestValidationLevelCache(t *testing.T) {
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	// CA/B Forum domain validated.
+	tmpl.PolicyIdentifiers = []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}}
+	dv := mustBuildChain(t, ca.issue(t, tmpl))[0]
+	unknown := mustBuildChain(t, ca.issue(t, leafTemplate()))[0]
+
+	cache := NewValidationLevelCache(1)
+	for i := 0; i < 2; i++ {
+		if got := cache.ValidationLevel(dv); got != DV.String() {
+			t.Errorf("ValidationLevel(dv) = %q, want %q", got, DV)
+		}
+	}
+	if got := cache.ValidationLevel(unknown); got != UnknownValidationLevel.String() {
+		t.Errorf("ValidationLevel(unknown) = %q, want %q", got, UnknownValidationLevel)
+	}
+	if got := cache.Len(); got != 1 {
+		t.Errorf("Len() = %d, want cache bounded to 1", got)
+	}
+}
+
+func TestShortLivedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.PolicyIdentifiers = []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}}
+	tmpl.NotAfter = tmpl.NotBefore.Add(7 * 24 * time.Hour)
+	shortLived := ca.issue(t, tmpl)
+	cert := mustBuildChain(t, shortLived)[0]
+
+	if got := ValidationLevel(cert); got != DV.String() {
+		t.Errorf("ValidationLevel(short-lived DV) = %q, want %q", got, DV)
+	}
+	if !IsShortLived(cert) {
+		t.Error("7-day certificate not reported as short-lived")
+	}
+	if IsShortLived(mustBuildChain(t, ca.issue(t, leafTemplate()))[0]) {
+		t.Error("90-day certificate reported as short-lived")
+	}
+
+	result, err := newTestChecker(newTestLog(t, "Test Log")).CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{shortLived, ca.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if !result.ShortLived {
+		t.Error("result does not flag the short-lived leaf")
+	}
+}
@@ -0,0 +1,54 @@
+package sct
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+// CertResult is the CT status of one certificate in a chain.
+type CertResult struct {
+	// Subject is the certificate's subject, for display.
+	Subject string
+	// Result holds the outcome of verifying the certificate's SCTs. It is empty if the
+	// certificate carries none.
+	Result *Result
+}
+
+// CheckChain verifies the SCTs of every certificate in the chain using the default checker.
+// See (*checker).CheckChain.
+func CheckChain(state *tls.ConnectionState) ([]*CertResult, error) {
+	return GetDefaultChecker().CheckChain(state)
+}
+
+// CheckChain is like CheckConnectionStateDetailed, but also verifies the SCTs embedded in the
+// intermediate certificates of the chain, for audits of CA hierarchies. It returns one result per
+// certificate, starting with the leaf. The leaf's result covers all of its SCTs; those of other
+// certificates cover their embedded SCTs, verified against the next certificate in the chain (or
+// a known issuer, see AddIssuer).
+func (c *checker) CheckChain(state *tls.ConnectionState) ([]*CertResult, error) {
+	leafResult, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := BuildCertificateChain(state.PeerCertificates)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*CertResult{{Subject: chain[0].Subject.String(), Result: leafResult}}
+	for i := 1; i < len(chain); i++ {
+		cert := chain[i]
+		scts := c.verifyEmbeddedSCTs(nil, cert, c.issuerFor(context.Background(), chain[i:]))
+		setIssuanceDelays(scts, cert)
+		results = append(results, &CertResult{
+			Subject: cert.Subject.String(),
+			Result: &Result{
+				SCTs:     scts,
+				Warnings: embeddedSCTWarnings(cert),
+			},
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,62 @@
+package sct
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCheckChainIntermediateSCTs(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	root := newTestCA(t)
+
+	// The intermediate carries an SCT, and is keyed with the root's shared leaf key.
+	intermediateTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(10),
+		Subject:               pkix.Name{CommonName: "Test Intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(180 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	intermediateCert := root.issueWithEmbeddedSCTs(t, intermediateTmpl, l)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := &testCA{key: root.leafKey.(*ecdsa.PrivateKey), cert: intermediateCert, leafKey: leafKey}
+	leaf := intermediate.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	results, err := newTestChecker(l).CheckChain(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, intermediateCert, root.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckChain: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d certificate results, want 3", len(results))
+	}
+
+	want := []struct {
+		subject string
+		valid   int
+	}{
+		{"CN=example.com", 1},
+		{"CN=Test Intermediate", 1},
+		{"CN=Test CA", 0},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Subject != w.subject || len(r.Result.SCTs) != w.valid || r.Result.ValidCount() != w.valid {
+			t.Errorf("certificate %d: subject %q with %d/%d valid SCTs, want %q with %d valid",
+				i, r.Subject, r.Result.ValidCount(), len(r.Result.SCTs), w.subject, w.valid)
+		}
+	}
+}
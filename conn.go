@@ -0,0 +1,57 @@
+package sct
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// maxConnChecks bounds how many times CheckConn verifies a connection whose peer certificates
+// keep changing while it does.
+const maxConnChecks = 3
+
+// CheckConn verifies the SCTs presented on a TLS connection using the default checker.
+// See (*checker).CheckConn.
+func CheckConn(conn *tls.Conn) (*Result, error) {
+	return GetDefaultChecker().CheckConn(conn)
+}
+
+// CheckConn is like CheckConnectionStateDetailed for the current state of conn, completing the
+// handshake first if needed. A client connection allowing renegotiation (tls.Config.Renegotiation)
+// may be presented different certificates by a later handshake: CheckConn verifies those of the
+// latest handshake and, should a renegotiation complete during verification, verifies the new
+// certificates rather than returning a result for stale ones.
+func (c *checker) CheckConn(conn *tls.Conn) (*Result, error) {
+	if err := conn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %v", err)
+	}
+
+	for i := 0; i < maxConnChecks; i++ {
+		state := conn.ConnectionState()
+		result, err := c.CheckConnectionStateDetailed(&state)
+		if err != nil {
+			return nil, err
+		}
+
+		current := conn.ConnectionState()
+		if samePeerCertificates(state.PeerCertificates, current.PeerCertificates) {
+			return result, nil
+		}
+	}
+
+	return nil, fmt.Errorf("peer certificates changed during each of %d checks", maxConnChecks)
+}
+
+// samePeerCertificates returns true if a and b hold the same certificates in the same order.
+func samePeerCertificates(a, b []*x509.Certificate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Raw, b[i].Raw) {
+			return false
+		}
+	}
+	return true
+}
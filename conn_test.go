@@ -0,0 +1,71 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCheckConn(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	leaf := ca.issue(t, tmpl)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate:                 [][]byte{leaf.Raw, ca.cert.Raw},
+			PrivateKey:                  ca.leafKey,
+			SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	result, err := newTestChecker(l).CheckConn(conn)
+	if err != nil {
+		t.Fatalf("CheckConn: %v", err)
+	}
+	if result.ValidCount() != 1 {
+		t.Errorf("got %d valid SCTs, want 1", result.ValidCount())
+	}
+}
+
+func TestSamePeerCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	first := ca.issue(t, leafTemplate())
+	renegotiated := ca.issue(t, leafTemplate())
+
+	if !samePeerCertificates([]*x509.Certificate{first, ca.cert}, []*x509.Certificate{first, ca.cert}) {
+		t.Error("identical chains reported as different")
+	}
+	// A renegotiation presenting another leaf must not be mistaken for the earlier handshake.
+	if samePeerCertificates([]*x509.Certificate{first, ca.cert}, []*x509.Certificate{renegotiated, ca.cert}) {
+		t.Error("chains with different leaves reported as the same")
+	}
+	if samePeerCertificates([]*x509.Certificate{first, ca.cert}, []*x509.Certificate{first}) {
+		t.Error("chains of different lengths reported as the same")
+	}
+}
@@ -0,0 +1,47 @@
+package sct
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// LogExchange is a request to a log and its response, as passed to Options.OnLogExchange.
+type LogExchange struct {
+	// Log is the description of the log the request was sent to.
+	Log    string
+	Method string
+	URL    string
+	// Status is the HTTP status code of the response, or zero if there was none.
+	Status int
+	// Response is the raw body of the response.
+	Response []byte
+	Duration time.Duration
+	// Err is set if no response was received, or its body could not be read.
+	Err error
+}
+
+// recordingTransport passes each request on, and reports it to record along with its response.
+type recordingTransport struct {
+	log    string
+	record func(*LogExchange)
+	base   http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	exchange := &LogExchange{Log: t.log, Method: req.Method, URL: req.URL.String(), Err: err}
+	if err == nil {
+		exchange.Status = resp.StatusCode
+		// Read the body to record it, and hand the caller a copy.
+		exchange.Response, exchange.Err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(exchange.Response))
+	}
+	exchange.Duration = time.Since(start)
+
+	t.record(exchange)
+	return resp, err
+}
@@ -0,0 +1,71 @@
+package sct
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+)
+
+func TestOnLogExchange(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	sth := l.signSTH(t, 2, make([]byte, 32))
+	sig, err := cttls.Marshal(sth.TreeHeadSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sthJSON, err := json.Marshal(&ct.GetSTHResponse{
+		TreeSize:          sth.TreeSize,
+		Timestamp:         sth.Timestamp,
+		SHA256RootHash:    sth.SHA256RootHash[:],
+		TreeHeadSignature: sig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/ct/v1/get-sth") {
+			w.Write(sthJSON)
+			return
+		}
+		http.Error(w, "no such entry", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	var mu sync.Mutex
+	var exchanges []*LogExchange
+	c := newTestChecker(l)
+	c.opts.OnLogExchange = func(e *LogExchange) {
+		mu.Lock()
+		exchanges = append(exchanges, e)
+		mu.Unlock()
+	}
+
+	logInfo, err := c.logInfoForLog(l.log)
+	if err != nil {
+		t.Fatalf("logInfoForLog: %v", err)
+	}
+	// The recorded body must still reach the client.
+	if _, err := logInfo.Client.GetSTH(context.Background()); err != nil {
+		t.Fatalf("GetSTH: %v", err)
+	}
+	logInfo.Client.GetProofByHash(context.Background(), make([]byte, 32), sth.TreeSize)
+
+	if len(exchanges) != 2 {
+		t.Fatalf("recorded %d exchanges, want 2", len(exchanges))
+	}
+	if e := exchanges[0]; e.Log != "Test Log" || e.Method != http.MethodGet || e.Status != http.StatusOK || string(e.Response) != string(sthJSON) {
+		t.Errorf("get-sth exchange = %+v", e)
+	}
+	if e := exchanges[1]; !strings.Contains(e.URL, "get-proof-by-hash") || e.Status != http.StatusNotFound || string(e.Response) != "no such entry\n" {
+		t.Errorf("get-proof-by-hash exchange = %+v", e)
+	}
+}
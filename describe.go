@@ -0,0 +1,109 @@
+package sct
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	ctx509util "github.com/google/certificate-transparency-go/x509util"
+)
+
+// SCTDescription describes an SCT and its issuing log, without verifying it.
+type SCTDescription struct {
+	Source SCTSource
+	// LogID is the hex-encoded KeyID of the log that issued the SCT.
+	LogID     string
+	Timestamp time.Time
+	// LogDescription and Operator describe the issuing log, if it is in the log list.
+	LogDescription string
+	Operator       string
+	// Resolved is true if the issuing log is in the log list.
+	Resolved bool
+	// Err is set if the SCT could not be decoded, in which case only Source is meaningful.
+	Err error
+}
+
+// DescribeSCTs describes the SCTs in the connection state using the default checker.
+// See (*checker).DescribeSCTs.
+func DescribeSCTs(state *tls.ConnectionState) ([]SCTDescription, error) {
+	return GetDefaultChecker().DescribeSCTs(state)
+}
+
+// DescribeSCTs decodes every SCT in the connection state (in the TLS extension, the stapled OCSP
+// response and the leaf certificate) and looks up its issuing log, without verifying signatures
+// or inclusion. A malformed OCSP response is reported as a single description with Err set.
+func (c *checker) DescribeSCTs(state *tls.ConnectionState) ([]SCTDescription, error) {
+	if state == nil {
+		return nil, errors.New("no TLS connection state")
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no peer certificates in TLS connection state")
+	}
+
+	if err := c.checkStateSize(state); err != nil {
+		return nil, err
+	}
+
+	chain, err := BuildCertificateChain(state.PeerCertificates[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	var descs []SCTDescription
+	for _, sct := range state.SignedCertificateTimestamps {
+		descs = append(descs, c.describeSCT(&ctx509.SerializedSCT{Val: sct}, SourceTLSExtension))
+	}
+
+	if len(state.OCSPResponse) > 0 {
+		scts, err := parseOCSPSCTs(state.OCSPResponse, state.PeerCertificates[0])
+		if err != nil {
+			descs = append(descs, SCTDescription{Source: SourceOCSP, Err: err})
+		}
+		for _, sct := range scts {
+			descs = append(descs, c.describeSCT(&ctx509.SerializedSCT{Val: sct}, SourceOCSP))
+		}
+	}
+
+	for i := range chain[0].SCTList.SCTList {
+		descs = append(descs, c.describeSCT(&chain[0].SCTList.SCTList[i], SourceEmbedded))
+	}
+
+	return descs, nil
+}
+
+// describeSCT decodes one SCT and resolves its log.
+func (c *checker) describeSCT(x509SCT *ctx509.SerializedSCT, source SCTSource) SCTDescription {
+	desc := SCTDescription{Source: source}
+
+	sct, err := ctx509util.ExtractSCT(x509SCT)
+	if err != nil {
+		desc.Err = err
+		return desc
+	}
+	desc.LogID = hex.EncodeToString(sct.LogID.KeyID[:])
+	desc.Timestamp = ct.TimestampToTime(sct.Timestamp)
+
+	if ctLog, operator := findLogByKeyHash(c.logList(), sct.LogID.KeyID); ctLog != nil {
+		desc.Resolved = true
+		desc.LogDescription = ctLog.Description
+		desc.Operator = operator.Name
+	}
+
+	return desc
+}
+
+// DecodeTLSSCTs decodes SCTs as delivered in the TLS extension, each independently and without a
+// certificate chain, e.g. to display the SCTs of a partial capture. Nothing is verified. Both
+// slices have an entry per SCT: a nil SCT with the error for each SCT that could not be decoded.
+func DecodeTLSSCTs(scts [][]byte) ([]*ct.SignedCertificateTimestamp, []error) {
+	decoded := make([]*ct.SignedCertificateTimestamp, len(scts))
+	errs := make([]error, len(scts))
+	for i, sct := range scts {
+		decoded[i], errs[i] = ctx509util.ExtractSCT(&ctx509.SerializedSCT{Val: sct})
+	}
+	return decoded, errs
+}
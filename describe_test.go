@@ -0,0 +1,62 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestDescribeSCTs(t *testing.T) {
+	known := newTestLog(t, "Known Log")
+	unknown := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), known)
+	when := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{
+			// Signed over the wrong leaf: describing an SCT does not verify it.
+			marshalSCT(t, unknown.sign(t, ca.precertLeaf(t, leafTemplate()), when)),
+			[]byte("garbage"),
+		},
+	}
+
+	descs, err := newTestChecker(known).DescribeSCTs(state)
+	if err != nil {
+		t.Fatalf("DescribeSCTs: %v", err)
+	}
+	if len(descs) != 3 {
+		t.Fatalf("got %d descriptions, want 3", len(descs))
+	}
+
+	if d := descs[0]; d.Source != SourceTLSExtension || d.Resolved || d.Err != nil ||
+		d.LogID != hex.EncodeToString(unknown.log.LogID) || !d.Timestamp.Equal(when) {
+		t.Errorf("TLS SCT from unknown log: got %+v", d)
+	}
+	if d := descs[1]; d.Source != SourceTLSExtension || d.Err == nil {
+		t.Errorf("undecodable TLS SCT: got %+v", d)
+	}
+	if d := descs[2]; d.Source != SourceEmbedded || !d.Resolved || d.LogDescription != "Known Log" || d.Operator != "Test Operator" {
+		t.Errorf("embedded SCT from known log: got %+v", d)
+	}
+}
+
+func TestDecodeTLSSCTs(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	sct := l.sign(t, merkleLeaf, time.Now())
+
+	scts, errs := DecodeTLSSCTs([][]byte{[]byte("garbage"), marshalSCT(t, sct)})
+	if len(scts) != 2 || len(errs) != 2 {
+		t.Fatalf("got %d SCTs and %d errors, want 2 of each", len(scts), len(errs))
+	}
+	if scts[0] != nil || errs[0] == nil {
+		t.Errorf("malformed SCT decoded as %v, error %v", scts[0], errs[0])
+	}
+	if errs[1] != nil || scts[1] == nil || scts[1].Timestamp != sct.Timestamp {
+		t.Errorf("DecodeTLSSCTs = %v, %v; want the SCT", scts[1], errs[1])
+	}
+}
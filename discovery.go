@@ -0,0 +1,150 @@
+package sct
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// DiscoveredLog is a log missing from the log list, as described by the discovery service at
+// Options.LogDiscoveryURL.
+type DiscoveredLog struct {
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	// Key is the log's DER-encoded public key. Its SHA-256 hash matches the SCT's KeyID.
+	Key []byte `json:"key"`
+	// SignatureValid is true if the SCT's signature verifies under Key. The SCT is still rejected,
+	// as the log is not trusted, but a valid signature shows the SCT genuinely comes from it.
+	SignatureValid bool `json:"-"`
+}
+
+const (
+	// maxDiscoveredLogs bounds the number of discovery service answers cached per checker, since
+	// any server can present SCTs with arbitrary KeyIDs. The least recently used answer is evicted.
+	maxDiscoveredLogs = 1024
+	// unknownLogTTL is how long an answer that a log is unknown is cached, so that logs the
+	// discovery service learns about later are eventually found.
+	unknownLogTTL = time.Hour
+)
+
+// discoveryEntry is a cached answer of the discovery service.
+type discoveryEntry struct {
+	keyID [sha256.Size]byte
+	// log is nil for logs unknown to the service.
+	log *DiscoveredLog
+	// expires is when an unknown log is looked up again. It is zero for known logs.
+	expires time.Time
+}
+
+// discoverLog asks the discovery service about the log with the given KeyID, and returns nil if
+// it does not know the log. Answers are cached, those about unknown logs for unknownLogTTL.
+func (c *checker) discoverLog(ctx context.Context, keyID [sha256.Size]byte) (*DiscoveredLog, error) {
+	s := c.shared()
+	if entry, ok := s.cachedDiscovery(keyID, c.opts.now()); ok {
+		return entry.log, nil
+	}
+
+	query := url.Values{}
+	query.Set("log_id", hex.EncodeToString(keyID[:]))
+	req, err := http.NewRequest(http.MethodGet, c.opts.LogDiscoveryURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(c.opts.userAgent()).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query log discovery service: %v", err)
+	}
+	defer resp.Body.Close()
+
+	entry := &discoveryEntry{keyID: keyID}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		entry.log = &DiscoveredLog{}
+		if err := json.NewDecoder(resp.Body).Decode(entry.log); err != nil {
+			return nil, fmt.Errorf("failed to parse log discovery response: %v", err)
+		}
+		if sha256.Sum256(entry.log.Key) != keyID {
+			return nil, fmt.Errorf("log discovery service returned a key not matching KeyID %x", keyID)
+		}
+	case http.StatusNotFound:
+		entry.expires = c.opts.now().Add(unknownLogTTL)
+	default:
+		return nil, fmt.Errorf("log discovery service returned %s", resp.Status)
+	}
+
+	s.cacheDiscovery(entry)
+	return entry.log, nil
+}
+
+// cachedDiscovery returns the cached answer about the log with the given KeyID, unless there is
+// none or it expired by now, and marks it as recently used.
+func (c *checker) cachedDiscovery(keyID [sha256.Size]byte, now time.Time) (*discoveryEntry, bool) {
+	c.discoveryMu.Lock()
+	defer c.discoveryMu.Unlock()
+
+	elem, ok := c.discovered[keyID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*discoveryEntry)
+	if !entry.expires.IsZero() && !now.Before(entry.expires) {
+		c.discoveryLRU.Remove(elem)
+		delete(c.discovered, keyID)
+		return nil, false
+	}
+	c.discoveryLRU.MoveToFront(elem)
+	return entry, true
+}
+
+// cacheDiscovery caches entry, evicting the least recently used answers beyond maxDiscoveredLogs.
+func (c *checker) cacheDiscovery(entry *discoveryEntry) {
+	c.discoveryMu.Lock()
+	defer c.discoveryMu.Unlock()
+
+	if c.discovered == nil {
+		c.discovered = make(map[[sha256.Size]byte]*list.Element)
+		c.discoveryLRU = list.New()
+	}
+	if elem, ok := c.discovered[entry.keyID]; ok {
+		c.discoveryLRU.Remove(elem)
+	}
+	c.discovered[entry.keyID] = c.discoveryLRU.PushFront(entry)
+	for c.discoveryLRU.Len() > maxDiscoveredLogs {
+		oldest := c.discoveryLRU.Back()
+		c.discoveryLRU.Remove(oldest)
+		delete(c.discovered, oldest.Value.(*discoveryEntry).keyID)
+	}
+}
+
+// discoveredSCTError returns the error for an SCT from a log missing from the log list, after
+// looking the log up with the discovery service. result.Discovered is set to a copy of the
+// discovered log, with the SCT's signature checked against merkleLeaf if it could be built.
+func (c *checker) discoveredSCTError(ctx context.Context, result *SCTResult, sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf, unknownErr error) error {
+	discovered, err := c.discoverLog(ctx, sct.LogID.KeyID)
+	if err != nil {
+		return fmt.Errorf("%w; %v", unknownErr, err)
+	}
+	if discovered == nil {
+		return fmt.Errorf("%w; unknown to the log discovery service", unknownErr)
+	}
+
+	d := *discovered
+	if merkleLeaf != nil {
+		if key, err := ctx509.ParsePKIXPublicKey(d.Key); err == nil {
+			if verifier, err := ct.NewSignatureVerifier(key); err == nil {
+				d.SignatureValid = verifier.VerifySCTSignature(*sct, ct.LogEntry{Leaf: *merkleLeaf}) == nil
+			}
+		}
+	}
+	result.Discovered = &d
+	return fmt.Errorf("%w; discovered as log %q at %s", unknownErr, d.Description, d.URL)
+}
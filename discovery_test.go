@@ -0,0 +1,120 @@
+package sct
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLogDiscovery(t *testing.T) {
+	trusted := newTestLog(t, "Trusted Log")
+	discoverable := newTestLog(t, "New Log")
+	unknown := newTestLog(t, "Unknown Log")
+
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if r.URL.Query().Get("log_id") != hex.EncodeToString(discoverable.log.LogID) {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(&DiscoveredLog{
+			Description: discoverable.log.Description,
+			URL:         "https://ct.example.com/",
+			Key:         discoverable.log.Key,
+		})
+	}))
+	defer srv.Close()
+
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{
+			marshalSCT(t, discoverable.sign(t, merkleLeaf, time.Now())),
+			marshalSCT(t, unknown.sign(t, merkleLeaf, time.Now())),
+		},
+	}
+	c := newTestChecker(trusted)
+	c.opts.LogDiscoveryURL = srv.URL
+
+	for i := 0; i < 2; i++ {
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			t.Fatalf("CheckConnectionStateDetailed: %v", err)
+		}
+		if len(result.SCTs) != 2 || result.ValidCount() != 0 {
+			t.Fatalf("got %d SCTs, %d valid; want 2 invalid", len(result.SCTs), result.ValidCount())
+		}
+		d := result.SCTs[0].Discovered
+		if d == nil || d.Description != "New Log" || !d.SignatureValid {
+			t.Errorf("discovered log = %+v, want New Log with a valid signature", d)
+		}
+		if result.SCTs[1].Discovered != nil {
+			t.Errorf("log unknown to the discovery service reported as %+v", result.SCTs[1].Discovered)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("discovery service queried %d times, want once per log", queries)
+	}
+
+	// Without a discovery URL, no lookups are made.
+	c.opts.LogDiscoveryURL = ""
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil || result.SCTs[0].Discovered != nil {
+		t.Errorf("without discovery: discovered = %+v, err = %v; want none", result.SCTs[0].Discovered, err)
+	}
+}
+
+func TestLogDiscoveryCache(t *testing.T) {
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	c := newTestChecker(newTestLog(t, "Trusted Log"))
+	c.opts.LogDiscoveryURL = srv.URL
+	c.opts.Now = func() time.Time { return now }
+	lookUp := func(keyID [sha256.Size]byte) {
+		t.Helper()
+		if d, err := c.discoverLog(context.Background(), keyID); err != nil || d != nil {
+			t.Fatalf("discoverLog = %+v, %v; want an unknown log", d, err)
+		}
+	}
+
+	// Unknown logs are looked up again once their answer expires.
+	var keyID [sha256.Size]byte
+	lookUp(keyID)
+	lookUp(keyID)
+	now = now.Add(unknownLogTTL)
+	lookUp(keyID)
+	if queries != 2 {
+		t.Errorf("discovery service queried %d times, want twice across the TTL", queries)
+	}
+
+	// The least recently used answers are evicted beyond maxDiscoveredLogs.
+	for i := 0; i < maxDiscoveredLogs; i++ {
+		binary.BigEndian.PutUint32(keyID[:], uint32(i+1))
+		lookUp(keyID)
+	}
+	if len(c.discovered) != maxDiscoveredLogs || c.discoveryLRU.Len() != maxDiscoveredLogs {
+		t.Errorf("%d answers cached, want %d", len(c.discovered), maxDiscoveredLogs)
+	}
+	queries = 0
+	lookUp([sha256.Size]byte{})
+	if queries != 1 {
+		t.Errorf("evicted answer not looked up again")
+	}
+}
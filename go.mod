@@ -4,5 +4,7 @@ go 1.13
 
 require (
 	github.com/google/certificate-transparency-go v1.1.1
-	github.com/zzylydx/zcrypto v0.1.17
+	github.com/google/trillian v1.3.11
+	golang.org/x/crypto v0.0.0-20201124201722-c8d3bf9c5392
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b // indirect
 )
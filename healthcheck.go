@@ -0,0 +1,146 @@
+package sct
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctclient "github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+// LogHealth is the outcome of checking one log in HealthCheckLogs.
+type LogHealth struct {
+	Log      string
+	Operator string
+	// Reachable is true if the log answered get-sth with a well-formed STH.
+	Reachable bool
+	// STHValid is true if that STH is signed by the log's key and not timestamped in the future.
+	STHValid bool
+	// Elapsed is the time taken to check the log.
+	Elapsed time.Duration
+	// Err explains why the log is unreachable or its STH invalid.
+	Err error
+}
+
+// HealthReport summarizes HealthCheckLogs.
+type HealthReport struct {
+	// Logs holds the outcome for each log checked, in log list order.
+	Logs      []*LogHealth
+	Reachable int
+	STHValid  int
+	// Failing holds the logs that are unreachable or serve an invalid STH, in log list order.
+	Failing []*LogHealth
+	// Elapsed is the time taken to check all the logs.
+	Elapsed time.Duration
+}
+
+// HealthCheckLogs checks the logs of the default checker's log list. See (*checker).HealthCheckLogs.
+func HealthCheckLogs(ctx context.Context, timeout time.Duration) (*HealthReport, error) {
+	return GetDefaultChecker().HealthCheckLogs(ctx, timeout)
+}
+
+// HealthCheckLogs fetches the current STH of every qualified, usable or read-only log in the
+// checker's log list, all at once, and verifies its signature. Each log is given up to timeout,
+// or unlimited time if timeout is zero. It is a pre-flight check for a scan: logs that cannot be
+// reached, or whose key does not match the log list, are reported in HealthReport.Failing rather
+// than as an error. An error is only returned if ctx is done before every log is checked.
+func (c *checker) HealthCheckLogs(ctx context.Context, timeout time.Duration) (*HealthReport, error) {
+	start := time.Now()
+	ll := c.logList().SelectByStatus(qualifiedLogs)
+
+	var logs []*LogHealth
+	var wg sync.WaitGroup
+	// interrupted is set if ctx cut short the check of any log.
+	var interrupted int32
+	for _, op := range ll.Operators {
+		for _, ctLog := range op.Logs {
+			health := &LogHealth{Log: ctLog.Description, Operator: op.Name}
+			logs = append(logs, health)
+
+			wg.Add(1)
+			go func(ctLog *loglist2.Log) {
+				defer wg.Done()
+				if c.healthCheckLog(ctx, ctLog, timeout, health) {
+					atomic.StoreInt32(&interrupted, 1)
+				}
+			}(ctLog)
+		}
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&interrupted) != 0 {
+		return nil, ctx.Err()
+	}
+
+	report := &HealthReport{Logs: logs, Elapsed: time.Since(start)}
+	for _, health := range logs {
+		if health.Reachable {
+			report.Reachable++
+		}
+		if health.STHValid {
+			report.STHValid++
+		} else {
+			report.Failing = append(report.Failing, health)
+		}
+	}
+	return report, nil
+}
+
+// healthCheckLog fetches and verifies the current STH of ctLog, recording the outcome in health.
+// It returns true if ctx was done before the STH could be fetched, rather than the log's own
+// timeout expiring or the log failing.
+func (c *checker) healthCheckLog(ctx context.Context, ctLog *loglist2.Log, timeout time.Duration, health *LogHealth) (interrupted bool) {
+	start := time.Now()
+	defer func() { health.Elapsed = time.Since(start) }()
+
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	logInfo, err := c.logInfoForLog(ctLog)
+	if err != nil {
+		health.Err = err
+		return false
+	}
+
+	sth, err := fetchSTHUnverified(ctx, logInfo.Client)
+	if err != nil {
+		health.Err = fmt.Errorf("failed to get STH for log %q: %v", ctLog.Description, err)
+		return parent.Err() != nil
+	}
+	health.Reachable = true
+
+	if err := logInfo.Verifier.VerifySTHSignature(*sth); err != nil {
+		health.Err = fmt.Errorf("invalid STH from log %q: %v", ctLog.Description, err)
+		return false
+	}
+	if ahead := ct.TimestampToTime(sth.Timestamp).Sub(c.opts.now()); ahead > c.opts.maxClockSkew() {
+		health.Err = fmt.Errorf("STH from log %q is timestamped %v in the future", ctLog.Description, ahead.Round(time.Second))
+		return false
+	}
+	health.STHValid = true
+	logInfo.SetSTH(sth)
+	return false
+}
+
+// fetchSTHUnverified fetches the current STH of the log behind client. A *ctclient.LogClient's
+// own signature check is skipped, to tell an unreachable log from one whose STH does not verify.
+func fetchSTHUnverified(ctx context.Context, client ctclient.CheckLogClient) (*ct.SignedTreeHead, error) {
+	logClient, ok := client.(*ctclient.LogClient)
+	if !ok {
+		return client.GetSTH(ctx)
+	}
+
+	var rsp ct.GetSTHResponse
+	if _, _, err := logClient.GetAndParse(ctx, ct.GetSTHPath, nil, &rsp); err != nil {
+		return nil, err
+	}
+	return rsp.ToSignedTreeHead()
+}
@@ -0,0 +1,88 @@
+package sct
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+)
+
+// serveSTH starts a log server answering get-sth with sth, after delay. The caller closes it.
+func serveSTH(t *testing.T, sth *ct.SignedTreeHead, delay time.Duration) *httptest.Server {
+	t.Helper()
+	sig, err := cttls.Marshal(sth.TreeHeadSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig})
+	}))
+	return srv
+}
+
+func TestHealthCheckLogs(t *testing.T) {
+	healthy := newTestLog(t, "Healthy Log")
+	healthySrv := serveSTH(t, healthy.signSTH(t, 1, make([]byte, 32)), 0)
+	defer healthySrv.Close()
+	healthy.log.URL = healthySrv.URL
+
+	// Serves an STH signed by another key.
+	badKey := newTestLog(t, "Bad Key Log")
+	badKeySrv := serveSTH(t, newTestLog(t, "Other").signSTH(t, 1, make([]byte, 32)), 0)
+	defer badKeySrv.Close()
+	badKey.log.URL = badKeySrv.URL
+
+	slow := newTestLog(t, "Slow Log")
+	slowSrv := serveSTH(t, slow.signSTH(t, 1, make([]byte, 32)), time.Minute)
+	defer slowSrv.Close()
+	slow.log.URL = slowSrv.URL
+
+	unreachable := newTestLog(t, "Unreachable Log")
+
+	c := newTestChecker(healthy, badKey, slow, unreachable)
+	report, err := c.HealthCheckLogs(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("HealthCheckLogs: %v", err)
+	}
+
+	if len(report.Logs) != 4 || report.Reachable != 2 || report.STHValid != 1 {
+		t.Errorf("got %d logs, %d reachable, %d with a valid STH; want 4, 2, 1", len(report.Logs), report.Reachable, report.STHValid)
+	}
+	var failing []string
+	for _, health := range report.Failing {
+		if health.Err == nil {
+			t.Errorf("failing log %q has no error", health.Log)
+		}
+		failing = append(failing, health.Log)
+	}
+	if len(failing) != 3 || failing[0] != "Bad Key Log" || failing[1] != "Slow Log" || failing[2] != "Unreachable Log" {
+		t.Errorf("failing logs = %v, want the bad key, slow and unreachable logs", failing)
+	}
+	if report.Elapsed <= 0 || report.Elapsed > 30*time.Second {
+		t.Errorf("Elapsed = %v, want the logs checked concurrently within their timeout", report.Elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.HealthCheckLogs(ctx, time.Second); err != context.Canceled {
+		t.Errorf("HealthCheckLogs(canceled) = %v, want %v", err, context.Canceled)
+	}
+
+	// A log checked without a request is not cut short by ctx: the report is complete.
+	badLog := newTestLog(t, "Bad Log")
+	badLog.log.Key = []byte("not a key")
+	report, err = newTestChecker(badLog).HealthCheckLogs(ctx, time.Second)
+	if err != nil || len(report.Failing) != 1 {
+		t.Errorf("HealthCheckLogs(canceled, no requests) = %+v, %v; want the failing log reported", report, err)
+	}
+}
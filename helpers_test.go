@@ -0,0 +1,268 @@
+package sct
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/loglist2"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// testOIDSCTList is the certificate extension carrying embedded SCTs (RFC 6962 s3.3).
+var testOIDSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// testOIDPoison marks a precertificate (RFC 6962 s3.1).
+var testOIDPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+// testLog is an in-memory CT log that can sign SCTs for test certificates.
+type testLog struct {
+	key *ecdsa.PrivateKey
+	log *loglist2.Log
+}
+
+func newTestLog(t testing.TB, description string) *testLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate log key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal log key: %v", err)
+	}
+	logID := sha256.Sum256(der)
+
+	return &testLog{
+		key: key,
+		log: &loglist2.Log{
+			Description: description,
+			LogID:       logID[:],
+			Key:         der,
+			// Nothing listens here: inclusion checks fail fast and fall back to the MMD grace.
+			URL: "http://127.0.0.1:1/",
+			MMD: 86400,
+			State: &loglist2.LogStates{
+				Usable: &loglist2.LogState{Timestamp: time.Now().Add(-365 * 24 * time.Hour)},
+			},
+		},
+	}
+}
+
+// sign returns an SCT from this log over the given leaf at the given time.
+func (l *testLog) sign(t testing.TB, leaf *ct.MerkleTreeLeaf, when time.Time) *ct.SignedCertificateTimestamp {
+	t.Helper()
+	sct := &ct.SignedCertificateTimestamp{
+		SCTVersion: ct.V1,
+		Timestamp:  uint64(when.UnixNano() / int64(time.Millisecond)),
+	}
+	copy(sct.LogID.KeyID[:], l.log.LogID)
+	l.resign(t, leaf, sct)
+
+	return sct
+}
+
+// resign replaces the SCT's signature, e.g. after a test has modified its fields.
+func (l *testLog) resign(t testing.TB, leaf *ct.MerkleTreeLeaf, sct *ct.SignedCertificateTimestamp) {
+	t.Helper()
+	entry := ct.LogEntry{Leaf: *leaf}
+	entry.Leaf.TimestampedEntry.Timestamp = sct.Timestamp
+	data, err := ct.SerializeSCTSignatureInput(*sct, entry)
+	if err != nil {
+		t.Fatalf("failed to serialize SCT signature input: %v", err)
+	}
+	sig, err := cttls.CreateSignature(*l.key, cttls.SHA256, data)
+	if err != nil {
+		t.Fatalf("failed to sign SCT: %v", err)
+	}
+	sct.Signature = ct.DigitallySigned(sig)
+}
+
+func marshalSCT(t testing.TB, sct *ct.SignedCertificateTimestamp) []byte {
+	t.Helper()
+	data, err := cttls.Marshal(*sct)
+	if err != nil {
+		t.Fatalf("failed to marshal SCT: %v", err)
+	}
+	return data
+}
+
+// newTestChecker returns a checker trusting exactly the given logs, all under one operator.
+func newTestChecker(logs ...*testLog) *checker {
+	op := &loglist2.Operator{Name: "Test Operator"}
+	for _, l := range logs {
+		op.Logs = append(op.Logs, l.log)
+	}
+	return &checker{ll: &loglist2.LogList{Operators: []*loglist2.Operator{op}}}
+}
+
+// testCA issues test certificates.
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	// leafKey is shared by every leaf so that a precertificate and its final
+	// certificate have identical TBS data.
+	leafKey crypto.Signer
+}
+
+func newTestCA(t testing.TB) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate leaf key: %v", err)
+	}
+	return &testCA{key: key, cert: cert, leafKey: leafKey}
+}
+
+// leafTemplate returns a minimal server certificate template.
+func leafTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+// issue signs tmpl with the CA, embedding scts (if any) in the SCT list extension.
+func (ca *testCA) issue(t testing.TB, tmpl *x509.Certificate, scts ...*ct.SignedCertificateTimestamp) *x509.Certificate {
+	t.Helper()
+	tmpl = copyTemplate(tmpl)
+	if len(scts) > 0 {
+		var list ctx509.SignedCertificateTimestampList
+		for _, sct := range scts {
+			list.SCTList = append(list.SCTList, ctx509.SerializedSCT{Val: marshalSCT(t, sct)})
+		}
+		listData, err := cttls.Marshal(list)
+		if err != nil {
+			t.Fatalf("failed to marshal SCT list: %v", err)
+		}
+		extValue, err := asn1.Marshal(listData)
+		if err != nil {
+			t.Fatalf("failed to marshal SCT list extension: %v", err)
+		}
+		tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, pkix.Extension{
+			Id:    []int(testOIDSCTList),
+			Value: extValue,
+		})
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, ca.leafKey.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("failed to create leaf certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse leaf certificate: %v", err)
+	}
+	return cert
+}
+
+func copyTemplate(tmpl *x509.Certificate) *x509.Certificate {
+	c := *tmpl
+	c.ExtraExtensions = append([]pkix.Extension(nil), tmpl.ExtraExtensions...)
+	return &c
+}
+
+// issuePrecert issues the poisoned precertificate for tmpl (RFC 6962 s3.1).
+func (ca *testCA) issuePrecert(t testing.TB, tmpl *x509.Certificate) *x509.Certificate {
+	t.Helper()
+	tmpl = copyTemplate(tmpl)
+	tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, pkix.Extension{
+		Id:       []int(testOIDPoison),
+		Critical: true,
+		Value:    asn1.NullBytes,
+	})
+	return ca.issue(t, tmpl)
+}
+
+// precertLeaf returns the Merkle leaf a log signs when it receives the
+// precertificate for tmpl.
+func (ca *testCA) precertLeaf(t testing.TB, tmpl *x509.Certificate) *ct.MerkleTreeLeaf {
+	t.Helper()
+	chain := mustBuildChain(t, ca.issuePrecert(t, tmpl), ca.cert)
+	leaf, err := ct.MerkleTreeLeafFromChain(chain, ct.PrecertLogEntryType, 0)
+	if err != nil {
+		t.Fatalf("failed to build precert leaf: %v", err)
+	}
+	return leaf
+}
+
+// issueWithEmbeddedSCTs issues a leaf carrying SCTs from each log, signed over
+// the precertificate for tmpl.
+func (ca *testCA) issueWithEmbeddedSCTs(t testing.TB, tmpl *x509.Certificate, logs ...*testLog) *x509.Certificate {
+	t.Helper()
+	leaf := ca.precertLeaf(t, tmpl)
+
+	var scts []*ct.SignedCertificateTimestamp
+	for _, l := range logs {
+		scts = append(scts, l.sign(t, leaf, time.Now().Add(-time.Minute)))
+	}
+	return ca.issue(t, tmpl, scts...)
+}
+
+func mustBuildChain(t testing.TB, certs ...*x509.Certificate) []*ctx509.Certificate {
+	t.Helper()
+	chain, err := BuildCertificateChain(certs)
+	if err != nil {
+		t.Fatalf("failed to build chain: %v", err)
+	}
+	return chain
+}
+
+// x509Leaf returns the Merkle leaf for an SCT delivered outside the certificate.
+func x509Leaf(t testing.TB, chain []*ctx509.Certificate) *ct.MerkleTreeLeaf {
+	t.Helper()
+	leaf, err := ct.MerkleTreeLeafFromChain(chain, ct.X509LogEntryType, 0)
+	if err != nil {
+		t.Fatalf("failed to build X509 leaf: %v", err)
+	}
+	return leaf
+}
+
+// newMultiOperatorChecker returns a checker trusting the given logs, each run by its own operator.
+func newMultiOperatorChecker(logs ...*testLog) *checker {
+	ll := &loglist2.LogList{}
+	for i, l := range logs {
+		ll.Operators = append(ll.Operators, &loglist2.Operator{
+			Name: fmt.Sprintf("Operator %d", i),
+			Logs: []*loglist2.Log{l.log},
+		})
+	}
+	return &checker{ll: ll}
+}
@@ -0,0 +1,310 @@
+package sct
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds the TCP connection and TLS handshake to each host in CheckHosts.
+const dialTimeout = 10 * time.Second
+
+// Host check statuses reported by CheckHosts.
+const (
+	// HostValid means at least one SCT passed verification.
+	HostValid = "valid"
+	// HostInvalid means the check ran but no SCT passed verification.
+	HostInvalid = "invalid"
+	// HostCheckError means the connection state could not be checked at all, or that the check
+	// was interrupted before any SCT passed verification.
+	HostCheckError = "check_error"
+	// HostNotApplicable means the certificate has no SCTs and chains to a private root, see
+	// Options.AllowNoSCTsForPrivateRoots.
+	HostNotApplicable = "not_applicable"
+	// HostDialError means the TCP connection or TLS handshake failed.
+	HostDialError = "dial_error"
+)
+
+// hostResult is the JSON line written by CheckHosts for each host, and the response of Handler.
+type hostResult struct {
+	Host       string          `json:"host,omitempty"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Validity   string          `json:"validity,omitempty"`
+	ShortLived bool            `json:"short_lived,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	SCTs       []hostSCTResult `json:"scts,omitempty"`
+}
+
+type hostSCTResult struct {
+	Source            string    `json:"source"`
+	Raw               []byte    `json:"raw"`
+	LogID             string    `json:"log_id"`
+	LogDescription    string    `json:"log_description,omitempty"`
+	Operator          string    `json:"operator,omitempty"`
+	Timestamp         time.Time `json:"timestamp"`
+	IssuanceDelay     float64   `json:"issuance_delay_seconds"`
+	InclusionVerified bool      `json:"inclusion_verified"`
+	Warnings          []string  `json:"warnings,omitempty"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// CheckHosts checks the hosts read from r using the default checker. See (*checker).CheckHosts.
+func CheckHosts(ctx context.Context, r io.Reader, w io.Writer, concurrency int) error {
+	return GetDefaultChecker().CheckHosts(ctx, r, w, concurrency)
+}
+
+// CheckHosts reads one host:port per line from r, dials up to concurrency hosts at a time, and
+// writes one JSON object per host to w, in completion order. Blank lines and lines starting with
+// '#' are ignored. Failing to reach a host is reported in its result with status HostDialError
+// rather than stopping the scan; CheckHosts only returns an error if r, w or Options.ResultStore
+// fail, or ctx is done. Results are saved to Options.ResultStore, if set, before being written.
+func (c *checker) CheckHosts(ctx context.Context, r io.Reader, w io.Writer, concurrency int) error {
+	return c.checkHosts(ctx, r, w, concurrency, nil)
+}
+
+// checkHosts implements CheckHosts, cloning config, if non-nil, for each handshake.
+func (c *checker) checkHosts(ctx context.Context, r io.Reader, w io.Writer, concurrency int, config *tls.Config) error {
+	if concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	var (
+		wg       sync.WaitGroup
+		wmu      sync.Mutex
+		writeErr error
+	)
+	hosts := make(chan string)
+	enc := json.NewEncoder(w)
+	progress := c.newProgressTracker()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				res, result := c.checkHost(ctx, host, config)
+				progress.record(result)
+				var saveErr error
+				if result != nil && c.opts.ResultStore != nil {
+					saveErr = c.opts.ResultStore.Save(host, result)
+				}
+
+				wmu.Lock()
+				if writeErr == nil {
+					writeErr = saveErr
+				}
+				if writeErr == nil {
+					writeErr = enc.Encode(res)
+				}
+				wmu.Unlock()
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(r)
+	var err error
+	for err == nil && scanner.Scan() {
+		host := strings.TrimSpace(scanner.Text())
+		if host == "" || strings.HasPrefix(host, "#") {
+			continue
+		}
+		select {
+		case hosts <- host:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(hosts)
+	wg.Wait()
+	progress.finish()
+
+	if err != nil {
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// checkHost dials host and checks the SCTs presented by the server. It returns the line to
+// write for host, and the result of the check if it ran.
+func (c *checker) checkHost(ctx context.Context, host string, config *tls.Config) (*hostResult, *Result) {
+	res := &hostResult{Host: host}
+
+	state, chainErr, err := c.dialHost(ctx, host, config)
+	if err != nil {
+		res.Status = HostDialError
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	result, err := c.CheckConnectionStateDetailedContext(ctx, state)
+	if result == nil {
+		res.Status = HostCheckError
+		res.Error = err.Error()
+		return res, nil
+	}
+	addChainWarning(result, chainErr)
+
+	res.setResult(result)
+	if err != nil {
+		res.setInterrupted(err)
+	}
+	return res, result
+}
+
+// setResult records the outcome of a check in res.
+func (res *hostResult) setResult(result *Result) {
+	res.Status = HostInvalid
+	switch {
+	case result.NotApplicable:
+		res.Status = HostNotApplicable
+	case result.ValidCount() > 0:
+		res.Status = HostValid
+	}
+	res.Validity = result.Validity.String()
+	res.ShortLived = result.ShortLived
+	res.Warnings = result.Warnings
+	for _, s := range result.SCTs {
+		sr := hostSCTResult{
+			Source:            s.Source.String(),
+			Raw:               s.Raw,
+			LogID:             s.LogID,
+			LogDescription:    s.LogDescription,
+			Operator:          s.Operator,
+			Timestamp:         s.Timestamp,
+			IssuanceDelay:     s.IssuanceDelay.Seconds(),
+			InclusionVerified: s.InclusionVerified,
+			Warnings:          s.Warnings,
+		}
+		if s.Err != nil {
+			sr.Error = s.Err.Error()
+		}
+		res.SCTs = append(res.SCTs, sr)
+	}
+}
+
+// setInterrupted records err, which interrupted the check whose result res holds. The SCTs
+// verified so far are kept, but a check which found no valid SCT is a HostCheckError rather than
+// HostInvalid: the SCTs left unverified might have passed.
+func (res *hostResult) setInterrupted(err error) {
+	res.Error = err.Error()
+	if res.Status == HostInvalid {
+		res.Status = HostCheckError
+	}
+}
+
+// DialAndCheck connects to host and verifies its SCTs using the default checker.
+// See (*checker).DialAndCheck.
+func DialAndCheck(ctx context.Context, host string, config *tls.Config) (*Result, error) {
+	return GetDefaultChecker().DialAndCheck(ctx, host, config)
+}
+
+// DialAndCheck performs a TLS handshake with host, given as "name:port", and verifies the SCTs
+// it presents as CheckConnectionStateDetailedContext does. Servers may only send SCTs in the TLS
+// extension, or a stapled OCSP response, to clients which ask for them: crypto/tls clients always
+// advertise both the signed_certificate_timestamp and status_request extensions, whatever config
+// holds, so such servers do send them. config may be nil; it is cloned, and its ServerName
+// defaults to host's name. The handshake is bounded by a 10 second timeout.
+//
+// The handshake does not verify the server's certificate, so that expired certificates and
+// certificates of private PKIs are checked too: see Result.Validity and Result.NotApplicable.
+// Instead, a chain which does not verify against config's RootCAs, or the system roots, for
+// config's ServerName is reported in Result.Warnings.
+func (c *checker) DialAndCheck(ctx context.Context, host string, config *tls.Config) (*Result, error) {
+	state, chainErr, err := c.dialHost(ctx, host, config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.CheckConnectionStateDetailedContext(ctx, state)
+	if result != nil {
+		addChainWarning(result, chainErr)
+	}
+	return result, err
+}
+
+// dialHost performs a TLS handshake with host, without verifying the server's certificate, and
+// returns the resulting connection state. chainErr is the outcome of verifying the peer
+// certificates against config's RootCAs, or the system roots, at the checker's current time.
+func (c *checker) dialHost(ctx context.Context, host string, config *tls.Config) (state *tls.ConnectionState, chainErr, err error) {
+	serverName, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	cfg := &tls.Config{}
+	if config != nil {
+		cfg = config.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = serverName
+	}
+	roots := cfg.RootCAs
+	cfg.InsecureSkipVerify = true
+
+	raw, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := tls.Client(raw, cfg)
+	defer conn.Close()
+
+	// The handshake is bounded by ctx's deadline, and aborted by closing the connection if ctx is
+	// canceled before.
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw.Close()
+		case <-handshakeDone:
+		}
+	}()
+	if err := conn.Handshake(); err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	cs := conn.ConnectionState()
+	if len(cs.PeerCertificates) == 0 {
+		return &cs, errors.New("no peer certificates"), nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, chainErr = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cfg.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   c.opts.now(),
+	})
+	return &cs, chainErr, nil
+}
+
+// addChainWarning records chainErr, the failure to verify the peer certificates of a connection,
+// in result. Certificates of private PKIs, reported as not applicable, are expected to fail.
+func addChainWarning(result *Result, chainErr error) {
+	if chainErr == nil || result.NotApplicable {
+		return
+	}
+	result.Warnings = append(result.Warnings, fmt.Sprintf("certificate chain does not verify: %v", chainErr))
+}
@@ -0,0 +1,226 @@
+package sct
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHosts(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	leaf := ca.issue(t, tmpl)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate:                 [][]byte{leaf.Raw, ca.cert.Raw},
+			PrivateKey:                  ca.leafKey,
+			SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	input := strings.Join([]string{ln.Addr().String(), "", "# comment", "127.0.0.1:1", "no-port"}, "\n")
+	var out strings.Builder
+	if err := newTestChecker(l).checkHosts(context.Background(), strings.NewReader(input), &out, 2, &tls.Config{RootCAs: roots}); err != nil {
+		t.Fatalf("CheckHosts: %v", err)
+	}
+
+	statuses := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var res hostResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatalf("bad result line %q: %v", scanner.Text(), err)
+		}
+		statuses[res.Host] = res.Status
+	}
+
+	want := map[string]string{
+		ln.Addr().String(): HostValid,
+		"127.0.0.1:1":      HostDialError,
+		"no-port":          HostDialError,
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("got results for %v, want %v", statuses, want)
+	}
+	for host, status := range want {
+		if statuses[host] != status {
+			t.Errorf("%s: status %q, want %q", host, statuses[host], status)
+		}
+	}
+}
+
+func TestCheckHostsBadConcurrency(t *testing.T) {
+	if err := newTestChecker().CheckHosts(context.Background(), strings.NewReader(""), &strings.Builder{}, 0); err == nil {
+		t.Error("CheckHosts accepted zero concurrency")
+	}
+}
+
+func TestDialAndCheck(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	leaf := ca.issue(t, tmpl)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	// crypto/tls servers only send SCTs to clients advertising the extension.
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate:                 [][]byte{leaf.Raw, ca.cert.Raw},
+			PrivateKey:                  ca.leafKey,
+			SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	result, err := newTestChecker(l).DialAndCheck(context.Background(), ln.Addr().String(), &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("DialAndCheck: %v", err)
+	}
+	if result.TLSExtension != TLSExtensionPresent || result.ValidCount() != 1 {
+		t.Errorf("TLS extension %v with %d valid SCTs, want one SCT in the extension", result.TLSExtension, result.ValidCount())
+	}
+}
+
+// serveTLS starts a TLS server presenting cert, and returns its listener. The caller closes it.
+func serveTLS(t *testing.T, cert tls.Certificate) net.Listener {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestCheckHostsUnverifiableChains(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	tmpl.NotBefore = time.Now().Add(-60 * 24 * time.Hour)
+	tmpl.NotAfter = time.Now().Add(-30 * 24 * time.Hour)
+	expired := ca.issueWithEmbeddedSCTs(t, tmpl, l)
+	expiredLn := serveTLS(t, tls.Certificate{Certificate: [][]byte{expired.Raw, ca.cert.Raw}, PrivateKey: ca.leafKey})
+	defer expiredLn.Close()
+	expiredHost := expiredLn.Addr().String()
+
+	private := newTestCA(t)
+	privateTmpl := leafTemplate()
+	privateTmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	privateLeaf := private.issue(t, privateTmpl)
+	privateLn := serveTLS(t, tls.Certificate{Certificate: [][]byte{privateLeaf.Raw, private.cert.Raw}, PrivateKey: private.leafKey})
+	defer privateLn.Close()
+	privateHost := privateLn.Addr().String()
+
+	c := newTestChecker(l)
+	c.opts.AllowNoSCTsForPrivateRoots = true
+	c.opts.PrivateRoots = x509.NewCertPool()
+	c.opts.PrivateRoots.AddCert(private.cert)
+
+	// Neither chain verifies against the system roots.
+	var out strings.Builder
+	if err := c.CheckHosts(context.Background(), strings.NewReader(expiredHost+"\n"+privateHost), &out, 2); err != nil {
+		t.Fatalf("CheckHosts: %v", err)
+	}
+	results := map[string]hostResult{}
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var res hostResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatalf("bad result line %q: %v", scanner.Text(), err)
+		}
+		results[res.Host] = res
+	}
+
+	if res := results[expiredHost]; res.Status != HostValid || res.Validity != ValidityExpired.String() || len(res.Warnings) == 0 {
+		t.Errorf("expired host: status %q, validity %q, warnings %v; want valid, expired, with a chain warning", res.Status, res.Validity, res.Warnings)
+	}
+	if res := results[privateHost]; res.Status != HostNotApplicable {
+		t.Errorf("private host: status %q (error %q), want %q", res.Status, res.Error, HostNotApplicable)
+	}
+
+	result, err := c.DialAndCheck(context.Background(), expiredHost, nil)
+	if err != nil {
+		t.Fatalf("DialAndCheck: %v", err)
+	}
+	if result.Validity != ValidityExpired {
+		t.Errorf("DialAndCheck: validity %v, want %v", result.Validity, ValidityExpired)
+	}
+}
+
+func TestCheckHostInterrupted(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	// The log never answers, so the check is interrupted while proving inclusion.
+	srv := serveSTH(t, l.signSTH(t, 1, make([]byte, 32)), time.Minute)
+	defer srv.Close()
+	l.log.URL = srv.URL
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	leaf := ca.issueWithEmbeddedSCTs(t, tmpl, l)
+	ln := serveTLS(t, tls.Certificate{Certificate: [][]byte{leaf.Raw, ca.cert.Raw}, PrivateKey: ca.leafKey})
+	defer ln.Close()
+	host := ln.Addr().String()
+
+	c := newTestChecker(l)
+	c.opts.RequireInclusion = true
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	res, _ := c.checkHost(ctx, host, nil)
+	if res.Status != HostCheckError || res.Error == "" {
+		t.Errorf("interrupted check: status %q, error %q; want %q with the interruption", res.Status, res.Error, HostCheckError)
+	}
+}
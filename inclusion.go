@@ -0,0 +1,390 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctclient "github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/ctutil"
+	ctjsonclient "github.com/google/certificate-transparency-go/jsonclient"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// VerifyInclusionWithProof verifies a previously fetched inclusion proof using the default checker.
+// See (*checker).VerifyInclusionWithProof.
+func VerifyInclusionWithProof(sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf, proof *ct.GetProofByHashResponse, sth *ct.SignedTreeHead) error {
+	return GetDefaultChecker().VerifyInclusionWithProof(sct, merkleLeaf, proof, sth)
+}
+
+// VerifyInclusionWithProof verifies, without network access, that the entry for sct over
+// merkleLeaf is included in the tree described by sth, using an inclusion proof fetched earlier
+// (e.g. with get-proof-by-hash for sth's tree size). The issuing log is resolved from the log list,
+// and sth must carry a valid signature from it. The SCT's own signature is not checked.
+func (c *checker) VerifyInclusionWithProof(sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf, proof *ct.GetProofByHashResponse, sth *ct.SignedTreeHead) error {
+	if sct == nil || merkleLeaf == nil || merkleLeaf.TimestampedEntry == nil {
+		return errors.New("no SCT and Merkle tree leaf to verify")
+	}
+	if proof == nil || sth == nil {
+		return errors.New("no inclusion proof and STH to verify against")
+	}
+
+	ctLog, _ := findLogByKeyHash(c.logList(), sct.LogID.KeyID)
+	if ctLog == nil {
+		return fmt.Errorf("%w with KeyID %x (%s)", errUnknownLog, sct.LogID.KeyID, c.logListDiagnostics())
+	}
+
+	logInfo, err := c.logInfoForLog(ctLog)
+	if err != nil {
+		return err
+	}
+
+	if err := logInfo.Verifier.VerifySTHSignature(*sth); err != nil {
+		return fmt.Errorf("invalid STH from log %q: %v", ctLog.Description, err)
+	}
+
+	// Set the SCT's timestamp on a copy, leaving the caller's leaf untouched.
+	entry := *merkleLeaf.TimestampedEntry
+	entry.Timestamp = sct.Timestamp
+	leaf := *merkleLeaf
+	leaf.TimestampedEntry = &entry
+	verifier := c.opts.inclusionVerifier()
+	leafHash, err := hashMerkleLeaf(verifier, &leaf)
+	if err != nil {
+		return err
+	}
+
+	if err := verifier.VerifyInclusionProof(proof.LeafIndex, int64(sth.TreeSize), proof.AuditPath, sth.SHA256RootHash[:], leafHash); err != nil {
+		return fmt.Errorf("failed to verify inclusion in log %q: %v", ctLog.Description, err)
+	}
+
+	return nil
+}
+
+// InclusionVerifier verifies inclusion proofs in a log's Merkle tree, see
+// Options.InclusionVerifier. Implementations must be safe for concurrent use.
+type InclusionVerifier interface {
+	// HashLeaf returns the Merkle tree hash of a log entry, a TLS-encoded MerkleTreeLeaf.
+	HashLeaf(leafInput []byte) []byte
+	// VerifyInclusionProof returns nil if proof shows that the entry with leafHash is at
+	// leafIndex in the tree of treeSize entries with the given root hash.
+	VerifyInclusionProof(leafIndex, treeSize int64, proof [][]byte, root, leafHash []byte) error
+}
+
+// RFC6962InclusionVerifier is the default InclusionVerifier, for the Merkle trees of RFC 6962.
+type RFC6962InclusionVerifier struct{}
+
+// HashLeaf implements InclusionVerifier.
+func (RFC6962InclusionVerifier) HashLeaf(leafInput []byte) []byte {
+	return rfc6962.DefaultHasher.HashLeaf(leafInput)
+}
+
+// VerifyInclusionProof implements InclusionVerifier.
+func (RFC6962InclusionVerifier) VerifyInclusionProof(leafIndex, treeSize int64, proof [][]byte, root, leafHash []byte) error {
+	return merkle.NewLogVerifier(rfc6962.DefaultHasher).VerifyInclusionProof(leafIndex, treeSize, proof, root, leafHash)
+}
+
+// hashMerkleLeaf returns verifier's hash of leaf.
+func hashMerkleLeaf(verifier InclusionVerifier, leaf *ct.MerkleTreeLeaf) ([]byte, error) {
+	leafInput, err := cttls.Marshal(*leaf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leaf hash: %v", err)
+	}
+	return verifier.HashLeaf(leafInput), nil
+}
+
+// errEntryNotFound is wrapped by inclusion errors which show that the log does not hold the
+// entry, as opposed to failures to reach the log.
+var errEntryNotFound = errors.New("entry not found in log")
+
+// getEntriesBatch is the number of entries requested at a time by the get-entries fallback.
+const getEntriesBatch = 256
+
+// getEntriesScanLimit bounds the entries read by the get-entries fallback for one SCT.
+const getEntriesScanLimit = 16 * getEntriesBatch
+
+// verifyInclusion checks that leaf, with the given SCT timestamp, is included in the log's current
+// tree, records the log's STH in logInfo and returns it. With Options.InclusionFallbackGetEntries,
+// a log not serving get-proof-by-hash is searched with get-entries instead, see findEntryAndProof.
+func (c *checker) verifyInclusion(ctx context.Context, logInfo *ctutil.LogInfo, leaf ct.MerkleTreeLeaf, timestamp uint64) (*ct.SignedTreeHead, error) {
+	sth, err := logInfo.Client.GetSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current STH for %q log: %v", logInfo.Description, err)
+	}
+	logInfo.SetSTH(sth)
+
+	if err := c.verifyPinnedConsistency(ctx, logInfo, sth); err != nil {
+		return nil, err
+	}
+
+	verifier := c.opts.inclusionVerifier()
+	leaf.TimestampedEntry.Timestamp = timestamp
+	leafHash, err := hashMerkleLeaf(verifier, &leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	index, auditPath, err := c.fetchInclusionProof(ctx, logInfo, verifier, leafHash, timestamp, sth.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifier.VerifyInclusionProof(index, int64(sth.TreeSize), auditPath, sth.SHA256RootHash[:], leafHash); err != nil {
+		return nil, fmt.Errorf("failed to verify inclusion proof at size %d: %v", sth.TreeSize, err)
+	}
+	return sth, nil
+}
+
+// fetchInclusionProof fetches the index and audit path of the entry with leafHash, and the given
+// SCT timestamp, in the log's tree of treeSize entries. With Options.InclusionFallbackGetEntries,
+// a log not serving get-proof-by-hash is searched with get-entries instead, see findEntryAndProof.
+func (c *checker) fetchInclusionProof(ctx context.Context, logInfo *ctutil.LogInfo, verifier InclusionVerifier, leafHash []byte, timestamp, treeSize uint64) (int64, [][]byte, error) {
+	rsp, err := logInfo.Client.GetProofByHash(ctx, leafHash, treeSize)
+	switch {
+	case err == nil:
+		return rsp.LeafIndex, rsp.AuditPath, nil
+	case c.opts.InclusionFallbackGetEntries && proofByHashUnavailable(err):
+		index, auditPath, err := findEntryAndProof(ctx, logInfo, verifier, leafHash, timestamp, treeSize)
+		if err != nil {
+			return 0, nil, fmt.Errorf("get-proof-by-hash failed for %q log, and so did the get-entries fallback: %v", logInfo.Description, err)
+		}
+		return index, auditPath, nil
+	case entryNotFound(err):
+		return 0, nil, fmt.Errorf("GetProofByHash(sct,size=%d): %w", treeSize, errEntryNotFound)
+	default:
+		return 0, nil, fmt.Errorf("failed to GetProofByHash(sct,size=%d): %v", treeSize, err)
+	}
+}
+
+// LogConsistencyError reports that a log's current STH is not consistent with the STH pinned for
+// it in Options.PinnedSTHs: the log rewrote or dropped entries it had committed to, or presented
+// a split view. Unlike failures to reach the log, this is evidence of log misbehavior.
+type LogConsistencyError struct {
+	Log         string
+	PinnedSize  uint64
+	CurrentSize uint64
+	Err         error
+}
+
+func (e *LogConsistencyError) Error() string {
+	return fmt.Sprintf("log %q misbehaved: STH at size %d is inconsistent with pinned STH at size %d: %v",
+		e.Log, e.CurrentSize, e.PinnedSize, e.Err)
+}
+
+// verifyPinnedConsistency verifies that sth, the log's current STH, is consistent with the STH
+// pinned for the log, if any. A LogConsistencyError is returned if the log provably misbehaved.
+func (c *checker) verifyPinnedConsistency(ctx context.Context, logInfo *ctutil.LogInfo, sth *ct.SignedTreeHead) error {
+	keyID := sha256.Sum256(logInfo.PublicKey)
+	pinned := c.opts.PinnedSTHs[hex.EncodeToString(keyID[:])]
+	if pinned == nil {
+		return nil
+	}
+	if err := logInfo.Verifier.VerifySTHSignature(*pinned); err != nil {
+		return fmt.Errorf("invalid pinned STH for %q log: %v", logInfo.Description, err)
+	}
+
+	inconsistent := func(err error) error {
+		return &LogConsistencyError{Log: logInfo.Description, PinnedSize: pinned.TreeSize, CurrentSize: sth.TreeSize, Err: err}
+	}
+	if sth.TreeSize < pinned.TreeSize {
+		return inconsistent(errors.New("tree shrank"))
+	}
+
+	var proof [][]byte
+	if pinned.TreeSize > 0 && pinned.TreeSize < sth.TreeSize {
+		var err error
+		proof, err = logInfo.Client.GetSTHConsistency(ctx, pinned.TreeSize, sth.TreeSize)
+		if err != nil {
+			return fmt.Errorf("failed to get consistency proof from size %d to %d for %q log: %v",
+				pinned.TreeSize, sth.TreeSize, logInfo.Description, err)
+		}
+	}
+
+	verifier := merkle.NewLogVerifier(rfc6962.DefaultHasher)
+	if err := verifier.VerifyConsistencyProof(int64(pinned.TreeSize), int64(sth.TreeSize),
+		pinned.SHA256RootHash[:], sth.SHA256RootHash[:], proof); err != nil {
+		return inconsistent(err)
+	}
+	return nil
+}
+
+// proofByHashUnavailable returns true if err, from get-proof-by-hash, suggests that the log does
+// not serve the endpoint. A log also answers 404 for a hash it does not know, e.g. an entry not
+// yet merged, which the fallback then fails to find as well.
+func proofByHashUnavailable(err error) bool {
+	var rspErr ctjsonclient.RspError
+	if !errors.As(err, &rspErr) {
+		return false
+	}
+	switch rspErr.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+// entryNotFound returns true if err, from get-proof-by-hash, is the log's answer that it has no
+// entry with the hash, rather than a network or server failure.
+func entryNotFound(err error) bool {
+	var rspErr ctjsonclient.RspError
+	return errors.As(err, &rspErr) && rspErr.StatusCode == http.StatusNotFound
+}
+
+// findEntryAndProof locates the entry with the given leaf hash in a log's tree of treeSize
+// entries using get-entries, and fetches its audit path with get-entry-and-proof.
+//
+// Logs sequence entries within their MMD of the SCT timestamp, so entries are nearly ordered by
+// timestamp: a binary search finds the first entry timestamped no earlier than the SCT, and the
+// entries on both sides of it are scanned outwards, alternately, until each side reaches an
+// entry timestamped more than an MMD away from the SCT. The scan is bounded by
+// getEntriesScanLimit, as busy logs sequence many entries per MMD.
+func findEntryAndProof(ctx context.Context, logInfo *ctutil.LogInfo, verifier InclusionVerifier, leafHash []byte, timestamp, treeSize uint64) (int64, [][]byte, error) {
+	client, ok := logInfo.Client.(*ctclient.LogClient)
+	if !ok {
+		return 0, nil, errors.New("log client does not support get-entries")
+	}
+
+	window := uint64(logInfo.MMD / time.Millisecond)
+	size := int64(treeSize)
+
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		rsp, err := client.GetRawEntries(ctx, mid, mid)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(rsp.Entries) == 0 {
+			return 0, nil, fmt.Errorf("no entry at index %d", mid)
+		}
+		ts, err := entryTimestamp(rsp.Entries[0].LeafInput)
+		if err != nil {
+			return 0, nil, fmt.Errorf("entry %d: %v", mid, err)
+		}
+		if ts < timestamp {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	// scan looks for the entry in a batch fetched from start, and reports whether the batch holds
+	// an entry beyond the MMD window on the side being scanned, after or before the SCT.
+	scan := func(start int64, entries []ct.LeafEntry, after bool) (index int64, found, beyond bool) {
+		for i, entry := range entries {
+			if bytes.Equal(verifier.HashLeaf(entry.LeafInput), leafHash) {
+				return start + int64(i), true, false
+			}
+			ts, err := entryTimestamp(entry.LeafInput)
+			if err != nil {
+				continue
+			}
+			if after && ts > timestamp+window || !after && ts+window < timestamp {
+				beyond = true
+			}
+		}
+		return 0, false, beyond
+	}
+
+	// Forwards from lo, and backwards from lo-1.
+	next, prev := lo, lo
+	for scanned := 0; scanned < getEntriesScanLimit && (next < size || prev > 0); {
+		if next < size {
+			end := next + getEntriesBatch - 1
+			if end >= size {
+				end = size - 1
+			}
+			entries, err := getEntries(ctx, client, next, end)
+			if err != nil {
+				return 0, nil, err
+			}
+			index, found, beyond := scan(next, entries, true)
+			if found {
+				return proveEntry(ctx, client, index, treeSize)
+			}
+			next = end + 1
+			scanned += len(entries)
+			if beyond {
+				next = size
+			}
+		}
+
+		if prev > 0 {
+			start := prev - getEntriesBatch
+			if start < 0 {
+				start = 0
+			}
+			entries, err := getEntries(ctx, client, start, prev-1)
+			if err != nil {
+				return 0, nil, err
+			}
+			index, found, beyond := scan(start, entries, false)
+			if found {
+				return proveEntry(ctx, client, index, treeSize)
+			}
+			prev = start
+			scanned += len(entries)
+			if beyond {
+				prev = 0
+			}
+		}
+	}
+
+	if next < size || prev > 0 {
+		return 0, nil, fmt.Errorf("entry not found within %d entries", getEntriesScanLimit)
+	}
+	return 0, nil, errors.New("entry not found")
+}
+
+// getEntries fetches the entries from start to end inclusive, with as many get-entries requests
+// as the log needs: logs may return fewer entries than requested.
+func getEntries(ctx context.Context, client *ctclient.LogClient, start, end int64) ([]ct.LeafEntry, error) {
+	var entries []ct.LeafEntry
+	for next := start; next <= end; {
+		rsp, err := client.GetRawEntries(ctx, next, end)
+		if err != nil {
+			return nil, err
+		}
+		if len(rsp.Entries) == 0 {
+			return nil, fmt.Errorf("no entries from index %d", next)
+		}
+		if int64(len(rsp.Entries)) > end-next+1 {
+			rsp.Entries = rsp.Entries[:end-next+1]
+		}
+		entries = append(entries, rsp.Entries...)
+		next += int64(len(rsp.Entries))
+	}
+	return entries, nil
+}
+
+// proveEntry fetches the audit path of the entry at index in the log's tree of treeSize entries.
+func proveEntry(ctx context.Context, client *ctclient.LogClient, index int64, treeSize uint64) (int64, [][]byte, error) {
+	proof, err := client.GetEntryAndProof(ctx, uint64(index), treeSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	return index, proof.AuditPath, nil
+}
+
+// entryTimestamp returns the timestamp of a TLS-encoded MerkleTreeLeaf.
+func entryTimestamp(leafInput []byte) (uint64, error) {
+	var leaf ct.MerkleTreeLeaf
+	if rest, err := cttls.Unmarshal(leafInput, &leaf); err != nil {
+		return 0, fmt.Errorf("failed to parse leaf: %v", err)
+	} else if len(rest) > 0 {
+		return 0, errors.New("trailing data after leaf")
+	}
+	if leaf.TimestampedEntry == nil {
+		return 0, errors.New("no timestamped entry in leaf")
+	}
+	return leaf.TimestampedEntry.Timestamp, nil
+}
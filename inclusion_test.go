@@ -0,0 +1,404 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	"github.com/google/trillian/merkle/rfc6962"
+)
+
+// signSTH returns an STH from the log for a tree with the given size and root.
+func (l *testLog) signSTH(t *testing.T, size uint64, root []byte) *ct.SignedTreeHead {
+	t.Helper()
+	sth := &ct.SignedTreeHead{
+		Version:   ct.V1,
+		TreeSize:  size,
+		Timestamp: uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+	}
+	copy(sth.SHA256RootHash[:], root)
+	data, err := ct.SerializeSTHSignatureInput(*sth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := cttls.CreateSignature(*l.key, cttls.SHA256, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sth.TreeHeadSignature = ct.DigitallySigned(sig)
+	return sth
+}
+
+func TestVerifyInclusionWithProof(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	other := newTestLog(t, "Other Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	sct := l.sign(t, merkleLeaf, time.Now())
+	c := newTestChecker(l, other)
+
+	// A two-entry tree: the SCT's entry, then another one.
+	entry := *merkleLeaf
+	timestamped := *merkleLeaf.TimestampedEntry
+	timestamped.Timestamp = sct.Timestamp
+	entry.TimestampedEntry = &timestamped
+	leafHash, err := ct.LeafHashForLeaf(&entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sibling := sha256.Sum256([]byte("another entry"))
+	root := rfc6962.DefaultHasher.HashChildren(leafHash[:], sibling[:])
+	proof := &ct.GetProofByHashResponse{LeafIndex: 0, AuditPath: [][]byte{sibling[:]}}
+	sth := l.signSTH(t, 2, root)
+
+	merkleLeaf.TimestampedEntry.Timestamp = 0
+	if err := c.VerifyInclusionWithProof(sct, merkleLeaf, proof, sth); err != nil {
+		t.Fatalf("valid inclusion proof rejected: %v", err)
+	}
+	if merkleLeaf.TimestampedEntry.Timestamp != 0 {
+		t.Error("VerifyInclusionWithProof modified the caller's leaf")
+	}
+
+	badProof := &ct.GetProofByHashResponse{LeafIndex: 1, AuditPath: proof.AuditPath}
+	if err := c.VerifyInclusionWithProof(sct, merkleLeaf, badProof, sth); err == nil {
+		t.Error("inclusion proof for the wrong index accepted")
+	}
+	if err := c.VerifyInclusionWithProof(sct, merkleLeaf, proof, other.signSTH(t, 2, root)); err == nil {
+		t.Error("STH signed by another log accepted")
+	}
+}
+
+func TestInclusionFallbackGetEntries(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	sct := l.sign(t, merkleLeaf, time.Now())
+
+	// A two-entry tree: an earlier entry, then the SCT's.
+	var leafInputs [][]byte
+	for _, ts := range []uint64{sct.Timestamp - 1000, sct.Timestamp} {
+		entry := *merkleLeaf
+		timestamped := *merkleLeaf.TimestampedEntry
+		timestamped.Timestamp = ts
+		entry.TimestampedEntry = &timestamped
+		input, err := cttls.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafInputs = append(leafInputs, input)
+	}
+	hash0 := rfc6962.DefaultHasher.HashLeaf(leafInputs[0])
+	hash1 := rfc6962.DefaultHasher.HashLeaf(leafInputs[1])
+	sth := l.signSTH(t, 2, rfc6962.DefaultHasher.HashChildren(hash0, hash1))
+	sig, err := cttls.Marshal(sth.TreeHeadSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			rsp = ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig}
+		case "/ct/v1/get-entries":
+			start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+			end, _ := strconv.Atoi(r.URL.Query().Get("end"))
+			var entries ct.GetEntriesResponse
+			for i := start; i <= end && i < len(leafInputs); i++ {
+				entries.Entries = append(entries.Entries, ct.LeafEntry{LeafInput: leafInputs[i]})
+			}
+			rsp = entries
+		case "/ct/v1/get-entry-and-proof":
+			if r.URL.Query().Get("leaf_index") != "1" {
+				http.NotFound(w, r)
+				return
+			}
+			rsp = ct.GetEntryAndProofResponse{LeafInput: leafInputs[1], AuditPath: [][]byte{hash0}}
+		default:
+			// Including get-proof-by-hash.
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, sct)},
+	}
+	c := newTestChecker(l)
+	c.opts.RequireInclusion = true
+
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 0 {
+		t.Error("inclusion proven without get-proof-by-hash or the fallback")
+	}
+
+	c = newTestChecker(l)
+	c.opts.RequireInclusion = true
+	c.opts.InclusionFallbackGetEntries = true
+	c.opts.RecordInclusionSTH = true
+	result, err = c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 || !result.SCTs[0].InclusionVerified {
+		t.Errorf("got %d valid SCTs (error %v); want 1 with inclusion verified", result.ValidCount(), result.SCTs[0].Err)
+	}
+	if got := result.SCTs[0].InclusionSTH; got == nil || got.TreeSize != sth.TreeSize || got.SHA256RootHash != sth.SHA256RootHash {
+		t.Errorf("InclusionSTH = %v, want the log's STH at size %d", got, sth.TreeSize)
+	}
+}
+
+func TestFindEntryInLargeLog(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	sought := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	other := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	base := uint64(time.Now().Add(-24*time.Hour).UnixNano() / int64(time.Millisecond))
+
+	// A log of a million entries, one per millisecond, whose MMD spans far more entries than the
+	// scan limit. The sought entry is sequenced out of order, by skew entries.
+	const treeSize, target = 1 << 20, 700000
+	var skew int64
+	leafInput := func(index int64) []byte {
+		ts := base + uint64(index)
+		merkleLeaf := other
+		if index == target {
+			ts = uint64(int64(ts) + skew)
+			merkleLeaf = sought
+		}
+		entry := *merkleLeaf
+		timestamped := *merkleLeaf.TimestampedEntry
+		timestamped.Timestamp = ts
+		entry.TimestampedEntry = &timestamped
+		input, err := cttls.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return input
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-entries":
+			start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+			end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+			// Like real logs, return at most 100 entries at a time.
+			var entries ct.GetEntriesResponse
+			for i := start; i <= end && i < start+100 && i < treeSize; i++ {
+				entries.Entries = append(entries.Entries, ct.LeafEntry{LeafInput: leafInput(i)})
+			}
+			rsp = entries
+		case "/ct/v1/get-entry-and-proof":
+			rsp = ct.GetEntryAndProofResponse{LeafInput: leafInput(target), AuditPath: [][]byte{make([]byte, 32)}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	c := newTestChecker(l)
+	logInfo, err := c.logInfoForLog(l.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, skew = range []int64{0, 300, -300} {
+		input := leafInput(target)
+		timestamp := base + uint64(target+skew)
+		leafHash := rfc6962.DefaultHasher.HashLeaf(input)
+		index, _, err := findEntryAndProof(context.Background(), logInfo, c.opts.inclusionVerifier(), leafHash, timestamp, treeSize)
+		if err != nil || index != target {
+			t.Errorf("skew %d: findEntryAndProof = %d, %v; want %d", skew, index, err, target)
+		}
+	}
+
+	if _, _, err := findEntryAndProof(context.Background(), logInfo, c.opts.inclusionVerifier(), make([]byte, 32), base+target, treeSize); err == nil {
+		t.Error("findEntryAndProof found an entry missing from the log")
+	}
+}
+
+func TestFailOnEntryNotFound(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	sth := l.signSTH(t, 1, make([]byte, 32))
+	sig, err := cttls.Marshal(sth.TreeHeadSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofStatus := http.StatusNotFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ct/v1/get-sth" {
+			json.NewEncoder(w).Encode(ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig})
+			return
+		}
+		http.Error(w, "no proof", proofStatus)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	c := newTestChecker(l)
+	c.opts.FailOnEntryNotFound = true
+	check := func(age time.Duration) error {
+		return c.CheckConnectionState(&tls.ConnectionState{
+			PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+			SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now().Add(-age)))},
+		})
+	}
+
+	if err := check(2 * time.Hour); err == nil {
+		t.Error("SCT missing from its log accepted past the grace period")
+	}
+	if err := check(time.Minute); err != nil {
+		t.Errorf("SCT missing from its log rejected within the grace period: %v", err)
+	}
+
+	// A server error is not definitive: the SCT is accepted within the MMD.
+	proofStatus = http.StatusServiceUnavailable
+	if err := check(2 * time.Hour); err != nil {
+		t.Errorf("SCT rejected on a transient log error: %v", err)
+	}
+}
+
+func TestPinnedSTHConsistency(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	hash0 := rfc6962.DefaultHasher.HashLeaf([]byte("entry 0"))
+	hash1 := rfc6962.DefaultHasher.HashLeaf([]byte("entry 1"))
+	sth := l.signSTH(t, 2, rfc6962.DefaultHasher.HashChildren(hash0, hash1))
+	sig, err := cttls.Marshal(sth.TreeHeadSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			rsp = ct.GetSTHResponse{TreeSize: sth.TreeSize, Timestamp: sth.Timestamp, SHA256RootHash: sth.SHA256RootHash[:], TreeHeadSignature: sig}
+		case "/ct/v1/get-sth-consistency":
+			rsp = ct.GetSTHConsistencyResponse{Consistency: [][]byte{hash1}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+	l.log.URL = srv.URL
+
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))},
+	}
+	keyID := sha256.Sum256(l.log.Key)
+
+	for _, tc := range []struct {
+		name   string
+		pinned *ct.SignedTreeHead
+		want   bool
+	}{
+		{"consistent", l.signSTH(t, 1, hash0), true},
+		{"same tree", sth, true},
+		{"rewritten entry", l.signSTH(t, 1, hash1), false},
+		{"tree shrank", l.signSTH(t, 3, hash0), false},
+	} {
+		c := newTestChecker(l)
+		c.opts.WarnOnInclusionFailure = true
+		c.opts.PinnedSTHs = map[string]*ct.SignedTreeHead{hex.EncodeToString(keyID[:]): tc.pinned}
+
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			t.Fatalf("%s: CheckConnectionStateDetailed: %v", tc.name, err)
+		}
+		s := result.SCTs[0]
+		var consistencyErr *LogConsistencyError
+		if got := !errors.As(s.Err, &consistencyErr); got != tc.want {
+			t.Errorf("%s: consistent = %v, want %v (error %v)", tc.name, got, tc.want, s.Err)
+		}
+		if tc.want && !s.Valid() {
+			t.Errorf("%s: SCT rejected: %v", tc.name, s.Err)
+		}
+	}
+}
+
+// plainTreeVerifier verifies proofs in a Merkle tree hashing leaves and nodes without the
+// domain separation prefixes of RFC 6962, for proofs of one level.
+type plainTreeVerifier struct{}
+
+func (plainTreeVerifier) HashLeaf(leafInput []byte) []byte {
+	h := sha256.Sum256(leafInput)
+	return h[:]
+}
+
+func (plainTreeVerifier) VerifyInclusionProof(leafIndex, treeSize int64, proof [][]byte, root, leafHash []byte) error {
+	if treeSize != 2 || len(proof) != 1 {
+		return errors.New("unsupported tree")
+	}
+	left, right := leafHash, proof[0]
+	if leafIndex == 1 {
+		left, right = right, left
+	}
+	if h := sha256.Sum256(append(append([]byte{}, left...), right...)); !bytes.Equal(h[:], root) {
+		return errors.New("root mismatch")
+	}
+	return nil
+}
+
+func TestCustomInclusionVerifier(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	sct := l.sign(t, merkleLeaf, time.Now())
+
+	entry := *merkleLeaf
+	timestamped := *merkleLeaf.TimestampedEntry
+	timestamped.Timestamp = sct.Timestamp
+	entry.TimestampedEntry = &timestamped
+	leafInput, err := cttls.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafHash := sha256.Sum256(leafInput)
+	sibling := sha256.Sum256([]byte("another entry"))
+	root := sha256.Sum256(append(leafHash[:], sibling[:]...))
+	proof := &ct.GetProofByHashResponse{LeafIndex: 0, AuditPath: [][]byte{sibling[:]}}
+	sth := l.signSTH(t, 2, root[:])
+
+	c := newTestChecker(l)
+	if err := c.VerifyInclusionWithProof(sct, merkleLeaf, proof, sth); err == nil {
+		t.Error("proof in a non-RFC 6962 tree accepted by the default verifier")
+	}
+	c.opts.InclusionVerifier = plainTreeVerifier{}
+	if err := c.VerifyInclusionWithProof(sct, merkleLeaf, proof, sth); err != nil {
+		t.Errorf("proof rejected by the custom verifier: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package sct
+
+import (
+	"context"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// AddIssuer adds cert to the checker's pool of known issuers, used to verify embedded SCTs
+// when a chain lacks the leaf's issuer. Certificates without a subject key ID are ignored,
+// since they cannot be matched against a leaf's authority key ID.
+func (c *checker) AddIssuer(cert *ctx509.Certificate) {
+	if len(cert.SubjectKeyId) == 0 {
+		return
+	}
+
+	s := c.shared()
+	s.issuersMu.Lock()
+	defer s.issuersMu.Unlock()
+	if s.issuers == nil {
+		s.issuers = make(map[string]*ctx509.Certificate)
+	}
+	s.issuers[string(cert.SubjectKeyId)] = cert
+}
+
+// issuerFor returns the issuer of chain[0]: the certificate of the chain named as its issuer,
+// otherwise chain[1] if present, otherwise a matching certificate from the issuer pool or, with
+// Options.FetchAIAIssuers, downloaded from the leaf's AIA URLs, or nil if the issuer is unknown.
+// Looking the issuer up by name tolerates servers sending their root before the intermediate.
+// ctx bounds the download.
+//
+// If chain[1] is a precertificate signing certificate (RFC 6962 s3.1), which only issues
+// precertificates, the final certificate was issued by the CA that issued it, chain[2]: embedded
+// SCTs cover that CA's key hash, not the precertificate signer's.
+func (c *checker) issuerFor(ctx context.Context, chain []*ctx509.Certificate) *ctx509.Certificate {
+	leaf := chain[0]
+	for _, cert := range chain[1:] {
+		if !ct.IsPreIssuer(cert) && checkIssuer(leaf, cert) == nil {
+			return cert
+		}
+	}
+
+	if len(chain) > 1 && !ct.IsPreIssuer(chain[1]) {
+		return chain[1]
+	}
+	if len(chain) > 2 {
+		return chain[2]
+	}
+
+	if len(leaf.AuthorityKeyId) > 0 {
+		s := c.shared()
+		s.issuersMu.RLock()
+		issuer := s.issuers[string(leaf.AuthorityKeyId)]
+		s.issuersMu.RUnlock()
+		if issuer != nil && checkIssuer(leaf, issuer) == nil {
+			return issuer
+		}
+	}
+
+	return c.fetchAIAIssuer(ctx, leaf)
+}
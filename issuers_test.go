@@ -0,0 +1,215 @@
+package sct
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIssuerPool(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	// The server omits its intermediate.
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+	c := newTestChecker(l)
+
+	if err := c.CheckConnectionState(state); err == nil {
+		t.Fatal("embedded SCT verified without an issuer")
+	}
+
+	// A different CA with the same subject name must not be picked.
+	c.AddIssuer(mustBuildChain(t, newTestCA(t).cert)[0])
+	if err := c.CheckConnectionState(state); err == nil {
+		t.Fatal("embedded SCT verified with an unrelated issuer")
+	}
+
+	c.AddIssuer(mustBuildChain(t, ca.cert)[0])
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Fatalf("CheckConnectionState with issuer from the pool: %v", err)
+	}
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 {
+		t.Errorf("got %d valid SCTs, want 1", result.ValidCount())
+	}
+}
+
+func TestPrecertSigningCertificateInChain(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	// A precertificate signing certificate (RFC 6962 s3.1), issued by the CA with the CT EKU.
+	signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "Test CA Precertificate Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 11129, 2, 4, 4}},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, ca.cert, &signerKey.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The SCTs cover the CA's key hash: the signer is skipped for the CA after it.
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, signer, ca.cert}}
+	c := newTestChecker(l)
+	c.opts.VerifyIssuerSignature = true
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Fatalf("CheckConnectionState with a precertificate signing certificate in the chain: %v", err)
+	}
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 {
+		t.Errorf("got %d valid SCTs, want 1: %+v", result.ValidCount(), result.SCTs)
+	}
+}
+
+func TestFetchAIAIssuers(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.cert.Raw)
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "zsct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "issuer.der")
+	if err := ioutil.WriteFile(path, ca.cert.Raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		url      string
+		extended bool
+		maxBytes int
+		want     bool
+	}{
+		{"http", srv.URL + "/issuer.der", false, 0, true},
+		{"file not allowed", "file://" + path, false, 0, false},
+		{"file", "file://" + path, true, 0, true},
+		{"file too large", "file://" + path, true, len(ca.cert.Raw) - 1, false},
+		{"http too large", srv.URL + "/issuer.der", false, len(ca.cert.Raw) - 1, false},
+		{"ldap without client", "ldap://ldap.example.com/cn=CA?cACertificate;binary", true, 0, false},
+	} {
+		tmpl := leafTemplate()
+		tmpl.IssuingCertificateURL = []string{tc.url}
+		// The server omits its intermediate, which is not in the issuer pool either.
+		state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, tmpl, l)}}
+		c := newTestChecker(l)
+		c.opts.FetchAIAIssuers = true
+		c.opts.AIAExtendedSchemes = tc.extended
+		c.opts.MaxCertificateBytes = tc.maxBytes
+
+		if err := c.CheckConnectionState(state); (err == nil) != tc.want {
+			t.Errorf("%s: CheckConnectionState = %v, want success %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestFetchedAIAIssuersCache(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(ca.cert.Raw)
+	}))
+	defer srv.Close()
+
+	tmpl := leafTemplate()
+	tmpl.IssuingCertificateURL = []string{srv.URL + "/issuer.der"}
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, tmpl, l)}}
+	c := newTestChecker(l)
+	c.opts.FetchAIAIssuers = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.CheckConnectionStateDetailedContext(ctx, state)
+	if n := atomic.LoadInt32(&fetches); n != 0 {
+		t.Errorf("fetched the issuer %d times with a canceled context, want 0", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.CheckConnectionState(state); err != nil {
+			t.Fatalf("CheckConnectionState: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("fetched the issuer %d times, want it cached after the first", n)
+	}
+	if len(c.issuers) != 0 {
+		t.Errorf("the fetched issuer was added to the issuer pool: %v", c.issuers)
+	}
+}
+
+func TestRootBeforeIntermediateInChain(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	root := newTestCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root.cert, &key.PublicKey, root.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := &testCA{key: key, cert: cert, leafKey: root.leafKey}
+	leaf := intermediate.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	// The server sends its self-signed root right after the leaf.
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, root.cert, intermediate.cert}}
+	result, err := newTestChecker(l).CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 {
+		t.Errorf("got %d valid SCTs, want 1: %v", result.ValidCount(), result.SCTs[0].Err)
+	}
+}
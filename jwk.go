@@ -0,0 +1,124 @@
+package sct
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk is a JSON Web Key (RFC 7517), with the members of public EC and RSA keys (RFC 7518).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseJWK returns the public key held by a JSON Web Key, for use as a log key, e.g. with
+// NewPinnedChecker or NewLocalLog after x509.MarshalPKIXPublicKey. EC keys on P-256, P-384 and
+// P-521 and RSA keys are supported, as those are the keys CT logs use.
+func ParseJWK(data []byte) (crypto.PublicKey, error) {
+	var key jwk
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse JWK: %v", err)
+	}
+	return key.publicKey()
+}
+
+// ParseJWKS returns the public keys of a JSON Web Key Set by KeyID (the SHA-256 hash of the
+// key's DER encoding), ready for NewPinnedChecker. Any key which cannot be used fails the set.
+func ParseJWKS(data []byte) (map[[sha256.Size]byte]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("no keys in JWKS")
+	}
+
+	keys := make(map[[sha256.Size]byte]crypto.PublicKey, len(set.Keys))
+	for i, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %v", i, k.Kid, err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): failed to marshal public key: %v", i, k.Kid, err)
+		}
+		keys[sha256.Sum256(der)] = pub
+	}
+	return keys, nil
+}
+
+// publicKey builds the public key described by k.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported JWK curve %q", k.Crv)
+		}
+		x, err := jwkInt("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := jwkInt("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("JWK point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "RSA":
+		n, err := jwkInt("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := jwkInt("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || e.Bit(0) == 0 {
+			return nil, errors.New("invalid JWK RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type %q", k.Kty)
+	}
+}
+
+// jwkInt decodes the base64url-encoded big-endian integer of the named JWK member.
+func jwkInt(name, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, fmt.Errorf("JWK member %q missing", name)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("JWK member %q: %v", name, err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
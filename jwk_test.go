@@ -0,0 +1,57 @@
+package sct
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestParseJWKS(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	pub := l.key.PublicKey
+
+	keys, err := ParseJWKS([]byte(fmt.Sprintf(`{"keys": [{"kty": "EC", "crv": "P-256", "kid": "log", "x": %q, "y": %q}]}`,
+		b64(pub.X), b64(pub.Y))))
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	c, err := NewPinnedChecker(keys, nil, Options{})
+	if err != nil {
+		t.Fatalf("NewPinnedChecker: %v", err)
+	}
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, leafTemplate(), l), ca.cert},
+	}
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Errorf("CheckConnectionState with a log key from a JWKS: %v", err)
+	}
+
+	offCurve := fmt.Sprintf(`{"kty": "EC", "crv": "P-256", "x": %q, "y": %q}`, b64(pub.X), b64(new(big.Int).Add(pub.Y, big.NewInt(1))))
+	if _, err := ParseJWK([]byte(offCurve)); err == nil {
+		t.Error("ParseJWK accepted a point not on the curve")
+	}
+}
+
+func TestParseJWKRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf(`{"kty": "RSA", "n": %q, "e": "AQAB"}`, base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+
+	pub, err := ParseJWK([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseJWK: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok || rsaPub.N.Cmp(key.N) != 0 || rsaPub.E != key.E {
+		t.Errorf("ParseJWK = %v, want the RSA public key", pub)
+	}
+}
@@ -0,0 +1,73 @@
+package sct
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Default input size limits, see Options.MaxCertificateBytes, Options.MaxSCTListBytes and
+// Options.MaxOCSPResponseBytes. They are well above what servers send in practice.
+const (
+	DefaultMaxCertificateBytes  = 64 << 10
+	DefaultMaxSCTListBytes      = 64 << 10
+	DefaultMaxOCSPResponseBytes = 64 << 10
+)
+
+// InputLimitError is returned for an input larger than its configured limit, before it is parsed.
+type InputLimitError struct {
+	// Input names the input, e.g. "certificate 0".
+	Input string
+	Size  int
+	Limit int
+}
+
+func (e *InputLimitError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds configured limit of %d bytes", e.Input, e.Size, e.Limit)
+}
+
+// checkLimit returns an InputLimitError if size exceeds limit. A negative limit means none.
+func checkLimit(input string, size, limit int) error {
+	if limit >= 0 && size > limit {
+		return &InputLimitError{Input: input, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// checkCertificateSize returns an error if the DER certificate der is over the configured limit.
+func (c *checker) checkCertificateSize(input string, der []byte) error {
+	return checkLimit(input, len(der), limitOrDefault(c.opts.MaxCertificateBytes, DefaultMaxCertificateBytes))
+}
+
+// checkSCTListSize returns an error if an SCT list of size bytes is over the configured limit.
+func (c *checker) checkSCTListSize(input string, size int) error {
+	return checkLimit(input, size, limitOrDefault(c.opts.MaxSCTListBytes, DefaultMaxSCTListBytes))
+}
+
+// checkStateSize returns an error if any input of state is over its configured limit: a
+// certificate, the SCTs of the TLS extension as a TLS-encoded list, or the OCSP response.
+func (c *checker) checkStateSize(state *tls.ConnectionState) error {
+	for i, cert := range state.PeerCertificates {
+		if err := c.checkCertificateSize(fmt.Sprintf("certificate %d", i), cert.Raw); err != nil {
+			return err
+		}
+	}
+
+	// Each SCT is preceded by its 2-byte length in the list.
+	size := 0
+	for _, sct := range state.SignedCertificateTimestamps {
+		size += 2 + len(sct)
+	}
+	if err := c.checkSCTListSize("TLS extension SCT list", size); err != nil {
+		return err
+	}
+
+	return checkLimit("OCSP response", len(state.OCSPResponse), limitOrDefault(c.opts.MaxOCSPResponseBytes, DefaultMaxOCSPResponseBytes))
+}
+
+// limitOrDefault returns limit, or def if limit is zero.
+func limitOrDefault(limit, def int) int {
+	if limit == 0 {
+		return def
+	}
+	return limit
+}
@@ -1,9 +1,16 @@
 package sct
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
@@ -34,40 +41,592 @@ func newDefaultLogList() *loglist2.LogList {
 }
 
 func newLogListFromSources(listURL, listSigURL, listPubKeyURL string) *loglist2.LogList {
-	jsonData, err := ctx509util.ReadFileOrURL(listURL, http.DefaultClient)
+	ll, err := fetchLogList(listURL, listSigURL, listPubKeyURL, defaultUserAgent)
 	if err != nil {
-		log.Fatalf("failed to fetch log list %s: %v", listURL, err) // 抓取log list，sig，pubkey
+		log.Fatal(err)
 	}
 
-	sigData, err := ctx509util.ReadFileOrURL(listSigURL, http.DefaultClient)
+	return ll
+}
+
+// fetchLogList fetches and verifies a signed log list, and returns its qualified logs.
+func fetchLogList(listURL, listSigURL, listPubKeyURL, userAgent string) (*loglist2.LogList, error) {
+	client := newHTTPClient(userAgent)
+
+	jsonData, err := ctx509util.ReadFileOrURL(listURL, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log list %s: %v", listURL, err) // 抓取log list，sig，pubkey
+	}
+
+	return verifyLogList(client, jsonData, listSigURL, listPubKeyURL)
+}
+
+// verifyLogList fetches the signature and public key of the log list jsonData, verifies it, and
+// returns its qualified logs.
+func verifyLogList(client *http.Client, jsonData []byte, listSigURL, listPubKeyURL string) (*loglist2.LogList, error) {
+	sigData, err := ctx509util.ReadFileOrURL(listSigURL, client)
 	if err != nil {
-		log.Fatalf("failed to fetch log list signature %s: %v", listSigURL, err)
+		return nil, fmt.Errorf("failed to fetch log list signature %s: %v", listSigURL, err)
 	}
 
-	pemData, err := ctx509util.ReadFileOrURL(listPubKeyURL, http.DefaultClient)
+	pemData, err := ctx509util.ReadFileOrURL(listPubKeyURL, client)
 	if err != nil {
-		log.Fatalf("failed to fetch log list public key %s: %v", listPubKeyURL, err)
+		return nil, fmt.Errorf("failed to fetch log list public key %s: %v", listPubKeyURL, err)
 	}
 
 	pubKey, _, _, err := ct.PublicKeyFromPEM(pemData)
 	if err != nil {
-		log.Fatalf("could not parse log list public key %s: %v", listPubKeyURL, err)
+		return nil, fmt.Errorf("could not parse log list public key %s: %v", listPubKeyURL, err)
 	}
 
 	ll, err := loglist2.NewFromSignedJSON(jsonData, sigData, pubKey) // 构成一个log list，签名、原始值、公钥
 	if err != nil {
-		log.Fatalf("could not verify log list signature: %v", err)
+		return nil, fmt.Errorf("could not verify log list signature: %v", err)
 	}
 
 	qualifiedLogs := ll.SelectByStatus(qualifiedLogs) // 根据状态选择active
-	return &qualifiedLogs
+	return &qualifiedLogs, nil
+}
+
+// cacheValidators are the HTTP cache validators of a fetched document, sent back in a
+// conditional request to fetch it again only if it changed.
+type cacheValidators struct {
+	etag         string
+	lastModified string
+}
+
+// fetchIfModified fetches the document at rawURL unless validators show that it is unchanged,
+// in which case notModified is true. Only http and https URLs support conditional requests;
+// others, such as file paths, are always read.
+func fetchIfModified(client *http.Client, rawURL string, validators cacheValidators) (data []byte, next cacheValidators, notModified bool, err error) {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		data, err := ctx509util.ReadFileOrURL(rawURL, client)
+		return data, cacheValidators{}, false, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, cacheValidators{}, false, err
+	}
+	if validators.etag != "" {
+		req.Header.Set("If-None-Match", validators.etag)
+	}
+	if validators.lastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, cacheValidators{}, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, validators, true, nil
+	default:
+		return nil, cacheValidators{}, false, fmt.Errorf("got HTTP status %q", resp.Status)
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, cacheValidators{}, false, err
+	}
+	next = cacheValidators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	return data, next, false, nil
+}
+
+// LoadAndMergeLogLists reads the (unsigned) JSON log lists at paths and merges them into one,
+// e.g. to combine curated production, staging and experimental lists for NewChecker. A log
+// appearing in several files, identified by KeyID, is taken from the last of them, along with its
+// operator. Operators are merged by name, keeping the details from the last file naming them.
+// Logs are not filtered by state.
+func LoadAndMergeLogLists(paths ...string) (*loglist2.LogList, error) {
+	type mergedLog struct {
+		log      *loglist2.Log
+		operator string
+	}
+	var logs []*mergedLog
+	byKeyID := make(map[string]*mergedLog)
+	operators := make(map[string]*loglist2.Operator)
+	var operatorOrder []string
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log list %s: %v", path, err)
+		}
+		ll, err := loglist2.NewFromJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse log list %s: %v", path, err)
+		}
+
+		for _, op := range ll.Operators {
+			if _, ok := operators[op.Name]; !ok {
+				operatorOrder = append(operatorOrder, op.Name)
+			}
+			operators[op.Name] = &loglist2.Operator{Name: op.Name, Email: op.Email}
+
+			for _, l := range op.Logs {
+				if m, ok := byKeyID[string(l.LogID)]; ok {
+					m.log, m.operator = l, op.Name
+					continue
+				}
+				m := &mergedLog{log: l, operator: op.Name}
+				byKeyID[string(l.LogID)] = m
+				logs = append(logs, m)
+			}
+		}
+	}
+
+	for _, m := range logs {
+		op := operators[m.operator]
+		op.Logs = append(op.Logs, m.log)
+	}
+
+	merged := &loglist2.LogList{}
+	for _, name := range operatorOrder {
+		if op := operators[name]; len(op.Logs) > 0 {
+			merged.Operators = append(merged.Operators, op)
+		}
+	}
+
+	return merged, nil
+}
+
+// LoadLogListAsOf fetches the comprehensive log list, loglist2.AllLogListURL, and reconstructs
+// from it the qualified, usable and read-only logs as of date, for re-running a past scan with
+// NewChecker and Options.Now set to date. See logListAsOf for how states are reconstructed.
+// The comprehensive list is not signed.
+func LoadLogListAsOf(ctx context.Context, date time.Time) (*loglist2.LogList, error) {
+	return loadLogListAsOf(ctx, loglist2.AllLogListURL, date)
 }
 
-func newLogInfoFromLog(ctLog *loglist2.Log) (*ctutil.LogInfo, error) {
+// loadLogListAsOf implements LoadLogListAsOf with the comprehensive log list at listURL.
+func loadLogListAsOf(ctx context.Context, listURL string, date time.Time) (*loglist2.LogList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(defaultUserAgent).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log list %s: %v", listURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch log list %s: got HTTP status %q", listURL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log list %s: %v", listURL, err)
+	}
+
+	ll, err := loglist2.NewFromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log list %s: %v", listURL, err)
+	}
+	return logListAsOf(ll, date), nil
+}
+
+// logListAsOf returns the logs of ll that were qualified, usable or read-only at date, in the
+// state they were in then. Log lists only record each log's current state and when it began, so
+// earlier states are inferred from the lifecycle pending, qualified, usable, then read-only or
+// retired, and carry no timestamp:
+//
+//   - a log qualified after date was still pending, and is left out;
+//   - a log usable after date was qualified;
+//   - a log read-only or retired after date was usable.
+//
+// Pending and rejected logs, and logs retired by date, are left out. A log that only joined the
+// list after date, but has since moved past the qualified state, cannot be told apart from one
+// that was already running, and is kept.
+func logListAsOf(ll *loglist2.LogList, date time.Time) *loglist2.LogList {
+	asOf := &loglist2.LogList{}
+	for _, op := range ll.Operators {
+		var logs []*loglist2.Log
+		for _, ctLog := range op.Logs {
+			state := logStateAsOf(ctLog.State, date)
+			if state == nil {
+				continue
+			}
+			l := *ctLog
+			l.State = state
+			logs = append(logs, &l)
+		}
+		if len(logs) > 0 {
+			asOp := *op
+			asOp.Logs = logs
+			asOf.Operators = append(asOf.Operators, &asOp)
+		}
+	}
+	return asOf
+}
+
+// logStateAsOf returns the state of a log at date given its current state, or nil if it was not
+// qualified, usable or read-only then. See logListAsOf.
+func logStateAsOf(current *loglist2.LogStates, date time.Time) *loglist2.LogStates {
+	if current == nil {
+		return nil
+	}
+
+	switch {
+	case current.Qualified != nil:
+		if date.Before(current.Qualified.Timestamp) {
+			return nil
+		}
+		return current
+	case current.Usable != nil:
+		if date.Before(current.Usable.Timestamp) {
+			return &loglist2.LogStates{Qualified: &loglist2.LogState{}}
+		}
+		return current
+	case current.ReadOnly != nil:
+		if date.Before(current.ReadOnly.Timestamp) {
+			return &loglist2.LogStates{Usable: &loglist2.LogState{}}
+		}
+		return current
+	case current.Retired != nil:
+		if date.Before(current.Retired.Timestamp) {
+			return &loglist2.LogStates{Usable: &loglist2.LogState{}}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// NewLocalLog returns a log list entry for a log outside the public log lists, such as a local
+// ct-test-srv instance in integration tests. publicKey is the log's public key, PEM or DER
+// encoded, from which its KeyID is derived; url is the log's base URL, used for inclusion proofs.
+// The log is usable from now on.
+//
+// Pass the logs to NewLocalLogList to build a log list for NewChecker.
+func NewLocalLog(description, url string, publicKey []byte, mmd time.Duration) (*loglist2.Log, error) {
+	der := publicKey
+	if block, _ := pem.Decode(publicKey); block != nil {
+		der = block.Bytes
+	}
+	if _, err := ctx509.ParsePKIXPublicKey(der); err != nil {
+		return nil, fmt.Errorf("failed to parse public key for log %q: %v", description, err)
+	}
+	logID := sha256.Sum256(der)
+
+	return &loglist2.Log{
+		Description: description,
+		LogID:       logID[:],
+		Key:         der,
+		URL:         url,
+		MMD:         int32(mmd / time.Second),
+		State: &loglist2.LogStates{
+			Usable: &loglist2.LogState{Timestamp: time.Now()},
+		},
+	}, nil
+}
+
+// NewLocalLogList returns a log list holding logs, all run by the named operator.
+func NewLocalLogList(operator string, logs ...*loglist2.Log) *loglist2.LogList {
+	return &loglist2.LogList{
+		Operators: []*loglist2.Operator{{Name: operator, Logs: logs}},
+	}
+}
+
+// findLogByKeyHash is like FindLogByKeyHash, but also returns the log's operator.
+func findLogByKeyHash(ll *loglist2.LogList, keyHash [sha256.Size]byte) (*loglist2.Log, *loglist2.Operator) {
+	for _, op := range ll.Operators {
+		for _, l := range op.Logs {
+			if bytes.Equal(l.LogID, keyHash[:]) {
+				return l, op
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// logList returns the checker's current log list.
+func (c *checker) logList() *loglist2.LogList {
+	s := c.shared()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ll
+}
+
+// ErrEmptyLogList is returned when the checker's log list has no logs, e.g. because the
+// configured list is empty or none of its logs are qualified. Every SCT would otherwise be
+// rejected as issued by an unknown log.
+var ErrEmptyLogList = errors.New("log list has no logs")
+
+// countLogs returns the number of logs in ll, which may be nil.
+func countLogs(ll *loglist2.LogList) int {
+	if ll == nil {
+		return 0
+	}
+	n := 0
+	for _, op := range ll.Operators {
+		n += len(op.Logs)
+	}
+	return n
+}
+
+// checkLogList returns ErrEmptyLogList if the checker's log list has no logs, unless
+// Options.LogDiscoveryURL can resolve logs outside it.
+func (c *checker) checkLogList() error {
+	if countLogs(c.logList()) == 0 && c.opts.LogDiscoveryURL == "" {
+		return ErrEmptyLogList
+	}
+	return nil
+}
+
+// SetLogList replaces the checker's log list, discarding any state cached for the previous one.
+func (c *checker) SetLogList(ll *loglist2.LogList) {
+	s := c.shared()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replaceLogList(ll)
+}
+
+// replaceLogList replaces the log list with ll, discarding any state cached for the previous
+// one, and returns the previous list. It must be called on the shared checker, holding c.mu for
+// writing.
+func (c *checker) replaceLogList(ll *loglist2.LogList) *loglist2.LogList {
+	old := c.ll
+	c.ll = ll
+	c.logInfos = nil
+	c.logListValidators = cacheValidators{}
+	return old
+}
+
+// RefreshLogList fetches the log list again from the sources configured in Options,
+// and replaces the checker's log list with it. On error, the current list is kept.
+//
+// A log list served over HTTP is fetched with a conditional request, using the ETag and
+// Last-Modified headers of the previous refresh, so that an unchanged list is neither downloaded
+// nor verified again: the current list is then kept as is.
+//
+// The log list of a checker made by NewPinnedChecker is never refreshed: ErrPinnedLogList is
+// returned instead.
+func (c *checker) RefreshLogList() error {
+	client := newHTTPClient(c.opts.userAgent())
+	listURL := c.opts.logListURL()
+
+	s := c.shared()
+	s.mu.RLock()
+	pinned := s.pinned
+	validators := s.logListValidators
+	s.mu.RUnlock()
+	if pinned {
+		return ErrPinnedLogList
+	}
+
+	jsonData, next, notModified, err := fetchIfModified(client, listURL, validators)
+	if err != nil {
+		return fmt.Errorf("failed to fetch log list %s: %v", listURL, err)
+	}
+	if notModified {
+		s.mu.Lock()
+		s.refreshedAt = time.Now()
+		s.mu.Unlock()
+		return nil
+	}
+
+	ll, err := verifyLogList(client, jsonData, c.opts.logListSigURL(), c.opts.logListPubKeyURL())
+	if err != nil {
+		return err
+	}
+
+	// The list replaced is diffed, not the one seen before fetching, so that concurrent refreshes
+	// each report their own change.
+	s.mu.Lock()
+	old := s.replaceLogList(ll)
+	s.refreshedAt = time.Now()
+	s.logListValidators = next
+	s.mu.Unlock()
+
+	if c.opts.OnLogListChange != nil {
+		if added, removed := diffLogLists(old, ll); len(added) > 0 || len(removed) > 0 {
+			c.opts.OnLogListChange(added, removed)
+		}
+	}
+
+	return nil
+}
+
+// diffLogLists returns the logs, identified by KeyID, in newList but not oldList, and those in
+// oldList but not newList.
+func diffLogLists(oldList, newList *loglist2.LogList) (added, removed []*loglist2.Log) {
+	keyIDs := func(ll *loglist2.LogList) map[string]bool {
+		ids := make(map[string]bool)
+		if ll == nil {
+			return ids
+		}
+		for _, op := range ll.Operators {
+			for _, l := range op.Logs {
+				ids[string(l.LogID)] = true
+			}
+		}
+		return ids
+	}
+	oldIDs, newIDs := keyIDs(oldList), keyIDs(newList)
+
+	for _, op := range newList.Operators {
+		for _, l := range op.Logs {
+			if !oldIDs[string(l.LogID)] {
+				added = append(added, l)
+			}
+		}
+	}
+	if oldList != nil {
+		for _, op := range oldList.Operators {
+			for _, l := range op.Logs {
+				if !newIDs[string(l.LogID)] {
+					removed = append(removed, l)
+				}
+			}
+		}
+	}
+
+	return added, removed
+}
+
+// HasLog returns true if the checker's log list holds the log with the given KeyID, the SHA-256
+// hash of its public key. Callers can use it to tell unknown logs from failed verifications.
+func (c *checker) HasLog(keyID []byte) bool {
+	if len(keyID) != sha256.Size {
+		return false
+	}
+
+	var keyHash [sha256.Size]byte
+	copy(keyHash[:], keyID)
+	ctLog, _ := findLogByKeyHash(c.logList(), keyHash)
+	return ctLog != nil
+}
+
+// logListDiagnostics describes the checker's log list, to explain why a log lookup missed.
+func (c *checker) logListDiagnostics() string {
+	s := c.shared()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := countLogs(s.ll)
+	if s.refreshedAt.IsZero() {
+		return fmt.Sprintf("%d logs loaded, log list never refreshed", n)
+	}
+	return fmt.Sprintf("%d logs loaded, log list refreshed at %s", n, s.refreshedAt.UTC().Format(time.RFC3339))
+}
+
+// logInfoEntry is a cached outcome of newLogInfoFromLog.
+type logInfoEntry struct {
+	logInfo *ctutil.LogInfo
+	err     error
+}
+
+// logInfoForLog returns the LogInfo for ctLog, building it on first use. Failures are cached too,
+// so a log with e.g. an unparseable key fails fast with the same error until the log list changes.
+func (c *checker) logInfoForLog(ctLog *loglist2.Log) (*ctutil.LogInfo, error) {
+	var logID [sha256.Size]byte
+	copy(logID[:], ctLog.LogID)
+
+	s := c.shared()
+	s.mu.RLock()
+	entry, ok := s.logInfos[logID]
+	s.mu.RUnlock()
+	if ok {
+		return entry.logInfo, entry.err
+	}
+
+	logInfo, err := newLogInfoFromLog(ctLog, s.opts.logURL(ctLog), s.opts.userAgent(), s.limiterFor(ctLog), s.opts.OnLogExchange)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.logInfos[logID]; ok {
+		// Another goroutine got there first.
+		return entry.logInfo, entry.err
+	}
+	if s.logInfos == nil {
+		s.logInfos = make(map[[sha256.Size]byte]*logInfoEntry)
+	}
+	s.logInfos[logID] = &logInfoEntry{logInfo: logInfo, err: err}
+
+	return logInfo, err
+}
+
+// WarmUp builds and caches the client for every qualified, usable or read-only log in the
+// checker's log list, so that the first checks of a scan do not pay for it. With
+// Options.WarmUpFetchSTH, it also fetches each log's current STH, warming up the connections to
+// the logs. Every log is attempted; the returned error reports the logs that failed, if any.
+func (c *checker) WarmUp(ctx context.Context) error {
+	ll := c.logList().SelectByStatus(qualifiedLogs)
+
+	var failed int
+	var firstErr error
+	for _, op := range ll.Operators {
+		for _, ctLog := range op.Logs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			err := c.warmUpLog(ctx, ctLog)
+			if err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to warm up %d logs, first error: %v", failed, firstErr)
+	}
+	return nil
+}
+
+// warmUpLog builds the client for ctLog and, if configured, fetches its current STH.
+func (c *checker) warmUpLog(ctx context.Context, ctLog *loglist2.Log) error {
+	logInfo, err := c.logInfoForLog(ctLog)
+	if err != nil {
+		return err
+	}
+	if !c.opts.WarmUpFetchSTH {
+		return nil
+	}
+
+	sth, err := logInfo.Client.GetSTH(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get STH for log %q: %v", ctLog.Description, err)
+	}
+	logInfo.SetSTH(sth)
+	return nil
+}
+
+// limiterFor returns the rate limiter for requests to ctLog, or nil if they are not limited.
+func (c *checker) limiterFor(ctLog *loglist2.Log) *tokenBucket {
+	var logID [sha256.Size]byte
+	copy(logID[:], ctLog.LogID)
+
+	s := c.shared()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limiter, ok := s.limiters[logID]; ok {
+		return limiter
+	}
+	if s.limiters == nil {
+		s.limiters = make(map[[sha256.Size]byte]*tokenBucket)
+	}
+	limiter := newTokenBucket(s.opts.logRateLimit(ctLog))
+	s.limiters[logID] = limiter
+
+	return limiter
+}
+
+// newLogInfoFromLog builds the LogInfo for ctLog, with a client for the log at url throttled by
+// limiter and reporting its exchanges to record, if non-nil.
+func newLogInfoFromLog(ctLog *loglist2.Log, url, userAgent string, limiter *tokenBucket, record func(*LogExchange)) (*ctutil.LogInfo, error) {
 	client, err := ctclient.New(
-		ctLog.URL,
-		http.DefaultClient,
-		ctjsonclient.Options{PublicKeyDER: ctLog.Key, UserAgent: "go-st"},
+		url,
+		logHTTPClient(ctLog.Description, limiter, record),
+		ctjsonclient.Options{PublicKeyDER: ctLog.Key, UserAgent: userAgent},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not create client for log %q: %v", ctLog.Description, err)
@@ -94,3 +653,49 @@ func newLogInfoFromLog(ctLog *loglist2.Log) (*ctutil.LogInfo, error) {
 
 	return logInfo, nil
 }
+
+// checkLogStateAt returns an error if ctLog was not accepting submissions at time t.
+// Only the log's current state is known, so earlier transitions are inferred: a qualified
+// log was pending before it became qualified, while a usable log was qualified before it
+// became usable.
+func checkLogStateAt(ctLog *loglist2.Log, t time.Time) error {
+	state, readOnly := ctLog.State.Active()
+	status := ctLog.State.LogStatus()
+
+	switch status {
+	case loglist2.QualifiedLogStatus:
+		if t.Before(state.Timestamp) {
+			return fmt.Errorf("SCT from log %q predates the log's qualification on %v", ctLog.Description, state.Timestamp)
+		}
+	case loglist2.UsableLogStatus:
+		// Already qualified before becoming usable.
+	case loglist2.ReadOnlyLogStatus:
+		if !t.Before(readOnly.Timestamp) {
+			return fmt.Errorf("SCT from log %q issued after the log became read-only on %v", ctLog.Description, readOnly.Timestamp)
+		}
+	case loglist2.RetiredLogStatus:
+		if !t.Before(state.Timestamp) {
+			return fmt.Errorf("SCT from log %q issued after the log was retired on %v", ctLog.Description, state.Timestamp)
+		}
+	default:
+		return fmt.Errorf("log %q is in state %v, which does not accept submissions", ctLog.Description, status)
+	}
+
+	return nil
+}
+
+// checkTemporalInterval returns an error if ctLog is a temporal shard whose interval does not
+// contain notAfter, meaning the shard should not have accepted the certificate.
+func checkTemporalInterval(ctLog *loglist2.Log, notAfter time.Time) error {
+	interval := ctLog.TemporalInterval
+	if interval == nil {
+		return nil
+	}
+
+	if notAfter.Before(interval.StartInclusive) || !notAfter.Before(interval.EndExclusive) {
+		return fmt.Errorf("certificate expiring %v is outside the temporal interval [%v, %v) of log %q",
+			notAfter, interval.StartInclusive, interval.EndExclusive, ctLog.Description)
+	}
+
+	return nil
+}
@@ -1,6 +1,26 @@
 package sct
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist2"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
 
 var (
 	testLogListPath       = "testdata/log_list.json"
@@ -14,3 +34,450 @@ func TestNewLogListSigned(t *testing.T) {
 		t.Fatal("returned log list is nil")
 	}
 }
+
+func TestLogInfoForLogCachesFailures(t *testing.T) {
+	bad := newTestLog(t, "Bad Key Log")
+	bad.log.Key = []byte("not a key")
+	c := newTestChecker(bad)
+
+	_, err := c.logInfoForLog(bad.log)
+	if err == nil {
+		t.Fatal("logInfoForLog succeeded for an unparseable key")
+	}
+	if _, again := c.logInfoForLog(bad.log); again != err {
+		t.Errorf("second lookup returned %v, want the cached error %v", again, err)
+	}
+
+	c.SetLogList(c.logList())
+	if _, again := c.logInfoForLog(bad.log); again == err {
+		t.Error("cached error survived a log list change")
+	}
+}
+
+func TestRefreshLogList(t *testing.T) {
+	c, err := NewChecker(newTestChecker(newTestLog(t, "Placeholder Log")).logList(), Options{
+		LogListURL:       testLogListPath,
+		LogListSigURL:    testLogListSigPath,
+		LogListPubKeyURL: testLogListPubKeyPath,
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	refreshed := c.logList()
+	if len(refreshed.Operators) == 0 {
+		t.Fatal("refreshed log list has no operators")
+	}
+
+	c.opts.LogListURL = "testdata/missing.json"
+	if err := c.RefreshLogList(); err == nil {
+		t.Fatal("RefreshLogList succeeded with a missing log list")
+	}
+	if c.logList() != refreshed {
+		t.Error("failed refresh replaced the log list")
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	agents := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.URL.Path] = r.UserAgent()
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/testdata/") {
+			http.ServeFile(w, r, r.URL.Path[1:])
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	l := newTestLog(t, "Test Log")
+	l.log.URL = srv.URL + "/log/"
+	c, err := NewChecker(newTestChecker(newTestLog(t, "Placeholder Log")).logList(), Options{
+		LogListURL:       srv.URL + "/" + testLogListPath,
+		LogListSigURL:    srv.URL + "/" + testLogListSigPath,
+		LogListPubKeyURL: srv.URL + "/" + testLogListPubKeyPath,
+		UserAgent:        "test-agent/1.0",
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	logInfo, err := c.logInfoForLog(l.log)
+	if err != nil {
+		t.Fatalf("logInfoForLog: %v", err)
+	}
+	// The log answers 404, but the request is still made.
+	logInfo.Client.GetSTH(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/" + testLogListPath, "/" + testLogListSigPath, "/" + testLogListPubKeyPath, "/log/ct/v1/get-sth"} {
+		if got := agents[path]; got != "test-agent/1.0" {
+			t.Errorf("%s: User-Agent %q, want %q", path, got, "test-agent/1.0")
+		}
+	}
+
+	if got := (&Options{}).userAgent(); got != defaultUserAgent {
+		t.Errorf("default User-Agent %q, want %q", got, defaultUserAgent)
+	}
+}
+
+func TestHasLogAndLookupDiagnostics(t *testing.T) {
+	known := newTestLog(t, "Known Log")
+	unknown := newTestLog(t, "Unknown Log")
+	c := newTestChecker(known)
+
+	if !c.HasLog(known.log.LogID) {
+		t.Error("HasLog missed a listed log")
+	}
+	if c.HasLog(unknown.log.LogID) {
+		t.Error("HasLog found an unlisted log")
+	}
+	if c.HasLog(known.log.LogID[:8]) {
+		t.Error("HasLog matched a truncated KeyID")
+	}
+
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	serialized := &ctx509.SerializedSCT{Val: marshalSCT(t, unknown.sign(t, merkleLeaf, time.Now()))}
+	_, err := c.checkOneSCT(serialized, merkleLeaf)
+	if err == nil || !strings.Contains(err.Error(), "1 logs loaded, log list never refreshed") {
+		t.Errorf("lookup miss before refresh: got %v", err)
+	}
+
+	c.opts = Options{
+		LogListURL:       testLogListPath,
+		LogListSigURL:    testLogListSigPath,
+		LogListPubKeyURL: testLogListPubKeyPath,
+	}
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	_, err = c.checkOneSCT(serialized, merkleLeaf)
+	if err == nil || !strings.Contains(err.Error(), "log list refreshed at") {
+		t.Errorf("lookup miss after refresh: got %v", err)
+	}
+}
+
+func TestLogURLOverrides(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer mirror.Close()
+
+	l := newTestLog(t, "Mirrored Log")
+	c := newTestChecker(l)
+	c.opts.LogURLOverrides = map[string]string{hex.EncodeToString(l.log.LogID): mirror.URL + "/mirror/"}
+
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	serialized := &ctx509.SerializedSCT{Val: marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))}
+	// The mirror cannot prove inclusion, but the SCT is within the MMD.
+	if _, err := c.checkOneSCT(serialized, merkleLeaf); err != nil {
+		t.Fatalf("SCT rejected: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 || paths[0] != "/mirror/ct/v1/get-sth" {
+		t.Errorf("mirror saw requests %q, want the inclusion check's get-sth", paths)
+	}
+}
+
+func TestLoadAndMergeLogLists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loglists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log1, log2, log3 := newTestLog(t, "Log 1"), newTestLog(t, "Log 2"), newTestLog(t, "Log 3")
+	log2v2 := *log2.log
+	log2v2.Description = "Log 2 (moved)"
+	write := func(name string, ll *loglist2.LogList) string {
+		data, err := json.Marshal(ll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	prod := write("prod.json", &loglist2.LogList{Operators: []*loglist2.Operator{
+		{Name: "Operator A", Logs: []*loglist2.Log{log1.log, log2.log}},
+	}})
+	staging := write("staging.json", &loglist2.LogList{Operators: []*loglist2.Operator{
+		{Name: "Operator B", Logs: []*loglist2.Log{&log2v2}},
+		{Name: "Operator A", Email: []string{"a@example.com"}, Logs: []*loglist2.Log{log3.log}},
+	}})
+
+	merged, err := LoadAndMergeLogLists(prod, staging)
+	if err != nil {
+		t.Fatalf("LoadAndMergeLogLists: %v", err)
+	}
+
+	var got []string
+	for _, op := range merged.Operators {
+		for _, l := range op.Logs {
+			got = append(got, op.Name+": "+l.Description)
+		}
+	}
+	want := []string{"Operator A: Log 1", "Operator A: Log 3", "Operator B: Log 2 (moved)"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("merged logs = %q, want %q", got, want)
+	}
+	if len(merged.Operators[0].Email) != 1 {
+		t.Errorf("operator details not taken from the last file: %+v", merged.Operators[0])
+	}
+
+	if _, err := LoadAndMergeLogLists(prod, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadAndMergeLogLists succeeded with a missing file")
+	}
+}
+
+func TestNewLocalLog(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: l.log.Key})
+
+	local, err := NewLocalLog("Local Log", "http://127.0.0.1:1/", keyPEM, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewLocalLog: %v", err)
+	}
+	if !bytes.Equal(local.LogID, l.log.LogID) {
+		t.Errorf("LogID = %x, want %x", local.LogID, l.log.LogID)
+	}
+
+	c, err := NewChecker(NewLocalLogList("Local Operator", local), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckConnectionState(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}); err != nil {
+		t.Errorf("CheckConnectionState: %v", err)
+	}
+
+	if _, err := NewLocalLog("Bad Log", "http://127.0.0.1:1/", []byte("not a key"), time.Hour); err == nil {
+		t.Error("NewLocalLog accepted an invalid key")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	c := newTestChecker(l)
+
+	if err := c.WarmUp(context.Background()); err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+	if len(c.logInfos) != 1 {
+		t.Errorf("got %d cached log clients, want 1", len(c.logInfos))
+	}
+
+	// Nothing listens at the test log's URL.
+	c.opts.WarmUpFetchSTH = true
+	if err := c.WarmUp(context.Background()); err == nil {
+		t.Error("WarmUp succeeded without fetching an STH")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.WarmUp(ctx); err != context.Canceled {
+		t.Errorf("WarmUp(canceled) = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestOnLogListChange(t *testing.T) {
+	removedLog := newTestLog(t, "Removed Log")
+	var added, removed []*loglist2.Log
+	calls := 0
+	c, err := NewChecker(newTestChecker(removedLog).logList(), Options{
+		LogListURL:       testLogListPath,
+		LogListSigURL:    testLogListSigPath,
+		LogListPubKeyURL: testLogListPubKeyPath,
+		OnLogListChange: func(a, r []*loglist2.Log) {
+			calls++
+			added, removed = a, r
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	if calls != 1 || len(added) == 0 || len(removed) != 1 || removed[0] != removedLog.log {
+		t.Errorf("got %d calls, %d added and %d removed logs; want 1 call, the test log removed", calls, len(added), len(removed))
+	}
+
+	// The same list again: no change to report.
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls after an unchanged refresh, want 1", calls)
+	}
+}
+
+func TestRefreshLogListConditional(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeFile(w, r, r.URL.Path[1:])
+	}))
+	defer srv.Close()
+
+	var changes int
+	c, err := NewChecker(newTestChecker(newTestLog(t, "Placeholder Log")).logList(), Options{
+		LogListURL:       srv.URL + "/" + testLogListPath,
+		LogListSigURL:    srv.URL + "/" + testLogListSigPath,
+		LogListPubKeyURL: srv.URL + "/" + testLogListPubKeyPath,
+		OnLogListChange:  func(added, removed []*loglist2.Log) { changes++ },
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.RefreshLogList(); err != nil {
+			t.Fatalf("RefreshLogList %d: %v", i, err)
+		}
+	}
+	if len(c.logList().Operators) == 0 {
+		t.Error("log list not kept after an unchanged refresh")
+	}
+	if changes != 1 {
+		t.Errorf("OnLogListChange called %d times, want 1", changes)
+	}
+	mu.Lock()
+	// The unchanged list is answered with 304, and its signature not fetched again.
+	if got := requests["/"+testLogListPath]; got != 2 {
+		t.Errorf("log list requested %d times, want 2", got)
+	}
+	if got := requests["/"+testLogListSigPath]; got != 1 {
+		t.Errorf("log list signature requested %d times, want 1", got)
+	}
+	mu.Unlock()
+
+	// A list replaced by the caller is fetched in full.
+	c.SetLogList(&loglist2.LogList{})
+	if err := c.RefreshLogList(); err != nil {
+		t.Fatalf("RefreshLogList: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := requests["/"+testLogListSigPath]; got != 2 {
+		t.Errorf("log list signature requested %d times after SetLogList, want 2", got)
+	}
+}
+
+func TestLoadLogListAsOf(t *testing.T) {
+	date := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	before, after := date.Add(-24*time.Hour), date.Add(24*time.Hour)
+	states := map[string]*loglist2.LogStates{
+		"qualified before": {Qualified: &loglist2.LogState{Timestamp: before}},
+		"qualified after":  {Qualified: &loglist2.LogState{Timestamp: after}},
+		"usable after":     {Usable: &loglist2.LogState{Timestamp: after}},
+		"read-only after":  {ReadOnly: &loglist2.ReadOnlyLogState{LogState: loglist2.LogState{Timestamp: after}}},
+		"read-only before": {ReadOnly: &loglist2.ReadOnlyLogState{LogState: loglist2.LogState{Timestamp: before}}},
+		"retired after":    {Retired: &loglist2.LogState{Timestamp: after}},
+		"retired before":   {Retired: &loglist2.LogState{Timestamp: before}},
+		"pending":          {Pending: &loglist2.LogState{Timestamp: before}},
+		"rejected":         {Rejected: &loglist2.LogState{Timestamp: after}},
+	}
+	want := map[string]loglist2.LogStatus{
+		"qualified before": loglist2.QualifiedLogStatus,
+		"usable after":     loglist2.QualifiedLogStatus,
+		"read-only after":  loglist2.UsableLogStatus,
+		"read-only before": loglist2.ReadOnlyLogStatus,
+		"retired after":    loglist2.UsableLogStatus,
+	}
+
+	op := &loglist2.Operator{Name: "Test Operator"}
+	for description, state := range states {
+		l := newTestLog(t, description)
+		l.log.State = state
+		op.Logs = append(op.Logs, l.log)
+	}
+	data, err := json.Marshal(&loglist2.LogList{Operators: []*loglist2.Operator{op, {Name: "Gone Operator"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	ll, err := loadLogListAsOf(context.Background(), srv.URL, date)
+	if err != nil {
+		t.Fatalf("loadLogListAsOf: %v", err)
+	}
+	if len(ll.Operators) != 1 {
+		t.Fatalf("got %d operators, want 1", len(ll.Operators))
+	}
+	got := make(map[string]loglist2.LogStatus)
+	for _, l := range ll.Operators[0].Logs {
+		got[l.Description] = l.State.LogStatus()
+	}
+	if len(got) != len(want) {
+		t.Errorf("got logs %v, want %v", got, want)
+	}
+	for description, status := range want {
+		if got[description] != status {
+			t.Errorf("log %q: status %v, want %v", description, got[description], status)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := loadLogListAsOf(ctx, srv.URL, date); err == nil {
+		t.Error("loadLogListAsOf succeeded with a canceled context")
+	}
+}
+
+func TestEmptyLogList(t *testing.T) {
+	if _, err := NewChecker(&loglist2.LogList{Operators: []*loglist2.Operator{{Name: "Empty Operator"}}}, Options{}); err != ErrEmptyLogList {
+		t.Errorf("NewChecker(empty list) = %v, want %v", err, ErrEmptyLogList)
+	}
+
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}
+
+	c := newTestChecker(l)
+	c.SetLogList(&loglist2.LogList{})
+	if err := c.CheckConnectionState(state); err != ErrEmptyLogList {
+		t.Errorf("CheckConnectionState = %v, want %v", err, ErrEmptyLogList)
+	}
+	if _, err := c.CheckConnectionStateDetailed(state); err != ErrEmptyLogList {
+		t.Errorf("CheckConnectionStateDetailed = %v, want %v", err, ErrEmptyLogList)
+	}
+	result, err := c.checkEmbedded(mustBuildChain(t, leaf)[0], mustBuildChain(t, ca.cert)[0])
+	if err != nil {
+		t.Fatalf("checkEmbedded: %v", err)
+	}
+	if len(result.SCTs) != 1 || result.SCTs[0].Err != ErrEmptyLogList {
+		t.Errorf("checkEmbedded SCT results %+v, want one failing with %v", result.SCTs, ErrEmptyLogList)
+	}
+}
@@ -0,0 +1,65 @@
+package sct
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// MerkleLeafHash returns the hash of the Merkle tree leaf a log created for chain[0] when it
+// issued an SCT with the given timestamp. This is the hash expected by a log's get-proof-by-hash
+// endpoint.
+//
+// For ct.X509LogEntryType, chain[0] is the certificate the SCT was delivered with (TLS extension
+// or OCSP). For ct.PrecertLogEntryType, chain[0] is either the final certificate carrying the
+// embedded SCT or the precertificate itself, and chain[1] is its issuer.
+func MerkleLeafHash(chain []*ctx509.Certificate, entryType ct.LogEntryType, timestamp uint64) ([sha256.Size]byte, error) {
+	if len(chain) == 0 {
+		return [sha256.Size]byte{}, errors.New("empty certificate chain")
+	}
+
+	var merkleLeaf *ct.MerkleTreeLeaf
+	var err error
+	switch {
+	case entryType == ct.PrecertLogEntryType && !chain[0].IsPrecertificate():
+		merkleLeaf, err = ct.MerkleTreeLeafForEmbeddedSCT(chain, timestamp)
+	case entryType == ct.X509LogEntryType, entryType == ct.PrecertLogEntryType:
+		merkleLeaf, err = ct.MerkleTreeLeafFromChain(chain, entryType, timestamp)
+	default:
+		return [sha256.Size]byte{}, fmt.Errorf("unsupported log entry type %v", entryType)
+	}
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	return ct.LeafHashForLeaf(merkleLeaf)
+}
+
+// merkleLeafNotAfter returns the expiry of the certificate or precertificate logged in merkleLeaf.
+func merkleLeafNotAfter(merkleLeaf *ct.MerkleTreeLeaf) (time.Time, error) {
+	entry := merkleLeaf.TimestampedEntry
+	if entry == nil {
+		return time.Time{}, errors.New("no timestamped entry in Merkle leaf")
+	}
+
+	var cert *ctx509.Certificate
+	var err error
+	switch entry.EntryType {
+	case ct.X509LogEntryType:
+		cert, err = ctx509.ParseCertificate(entry.X509Entry.Data)
+	case ct.PrecertLogEntryType:
+		cert, err = ctx509.ParseTBSCertificate(entry.PrecertEntry.TBSCertificate)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported log entry type %v", entry.EntryType)
+	}
+	// The CT parser returns the certificate alongside non-fatal errors.
+	if cert == nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
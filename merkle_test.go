@@ -0,0 +1,50 @@
+package sct
+
+import (
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+func TestMerkleLeafHash(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	const timestamp = 1600000000000
+
+	final := mustBuildChain(t, ca.issueWithEmbeddedSCTs(t, tmpl, l), ca.cert)
+	precert := mustBuildChain(t, ca.issuePrecert(t, tmpl), ca.cert)
+
+	fromFinal, err := MerkleLeafHash(final, ct.PrecertLogEntryType, timestamp)
+	if err != nil {
+		t.Fatalf("MerkleLeafHash(final certificate): %v", err)
+	}
+	fromPrecert, err := MerkleLeafHash(precert, ct.PrecertLogEntryType, timestamp)
+	if err != nil {
+		t.Fatalf("MerkleLeafHash(precertificate): %v", err)
+	}
+	if fromFinal != fromPrecert {
+		t.Errorf("precert leaf hash differs between final certificate (%x) and precertificate (%x)", fromFinal, fromPrecert)
+	}
+
+	merkleLeaf := x509Leaf(t, final)
+	merkleLeaf.TimestampedEntry.Timestamp = timestamp
+	want, err := ct.LeafHashForLeaf(merkleLeaf)
+	if err != nil {
+		t.Fatalf("LeafHashForLeaf: %v", err)
+	}
+	got, err := MerkleLeafHash(final, ct.X509LogEntryType, timestamp)
+	if err != nil {
+		t.Fatalf("MerkleLeafHash(X509 entry): %v", err)
+	}
+	if got != want {
+		t.Errorf("X509 leaf hash = %x, want %x", got, want)
+	}
+	if got == fromFinal {
+		t.Error("X509 and precert entries have the same leaf hash")
+	}
+
+	if _, err := MerkleLeafHash(final[:1], ct.PrecertLogEntryType, timestamp); err == nil {
+		t.Error("MerkleLeafHash succeeded for a precert entry without issuer")
+	}
+}
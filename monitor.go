@@ -0,0 +1,227 @@
+package sct
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// defaultMonitorURL is the CT monitor queried by FindLoggedCertificates.
+const defaultMonitorURL = "https://crt.sh/"
+
+// LoggedCertificate is a logged certificate reported by a CT monitor.
+type LoggedCertificate struct {
+	// ID is the monitor's identifier for the certificate.
+	ID           int64
+	IssuerName   string
+	CommonName   string
+	SerialNumber string
+	// EntryTimestamp is when the monitor first saw the certificate in a log.
+	EntryTimestamp time.Time
+	NotBefore      time.Time
+	NotAfter       time.Time
+}
+
+// monitorEntry is an entry of a crt.sh JSON response.
+type monitorEntry struct {
+	ID             int64  `json:"id"`
+	IssuerName     string `json:"issuer_name"`
+	CommonName     string `json:"common_name"`
+	SerialNumber   string `json:"serial_number"`
+	EntryTimestamp string `json:"entry_timestamp"`
+	NotBefore      string `json:"not_before"`
+	NotAfter       string `json:"not_after"`
+	// CertDER is the certificate itself, which crt.sh leaves out but some monitors include.
+	CertDER []byte `json:"cert_der"`
+}
+
+// FindLoggedCertificates asks a CT monitor for logged certificates using the default checker.
+// See (*checker).FindLoggedCertificates.
+func FindLoggedCertificates(ctx context.Context, serial *big.Int, issuer *ctx509.Certificate) ([]LoggedCertificate, error) {
+	return GetDefaultChecker().FindLoggedCertificates(ctx, serial, issuer)
+}
+
+// FindLoggedCertificates reports whether certificates with the given serial number, issued by
+// issuer, have been logged, for certificates the caller does not have. RFC 6962 logs cannot be
+// searched by serial number, so this asks a crt.sh-compatible monitor (Options.MonitorURL) instead
+// of the logs themselves, and trusts its answer. Monitors identify issuers by name, so entries
+// are matched on the issuer's common name and organization. An empty result means the monitor
+// knows of no such certificate.
+func (c *checker) FindLoggedCertificates(ctx context.Context, serial *big.Int, issuer *ctx509.Certificate) ([]LoggedCertificate, error) {
+	if serial == nil || issuer == nil {
+		return nil, errors.New("serial number and issuer are required")
+	}
+
+	query := url.Values{}
+	query.Set("serial", fmt.Sprintf("%x", serial))
+	query.Set("output", "json")
+	monitorURL := c.opts.monitorURL() + "?" + query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, monitorURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(c.opts.userAgent()).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monitor: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("monitor returned %s", resp.Status)
+	}
+
+	var entries []monitorEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to parse monitor response: %v", err)
+	}
+
+	var certs []LoggedCertificate
+	for _, e := range entries {
+		if !issuerNameMatches(e.IssuerName, issuer) {
+			continue
+		}
+		certs = append(certs, LoggedCertificate{
+			ID:             e.ID,
+			IssuerName:     e.IssuerName,
+			CommonName:     e.CommonName,
+			SerialNumber:   e.SerialNumber,
+			EntryTimestamp: parseMonitorTime(e.EntryTimestamp),
+			NotBefore:      parseMonitorTime(e.NotBefore),
+			NotAfter:       parseMonitorTime(e.NotAfter),
+		})
+	}
+
+	return certs, nil
+}
+
+// issuerNameMatches returns true if the monitor's issuer name, e.g. "C=US, O=Let's Encrypt, CN=R3",
+// has the same common name and organization as issuer's subject.
+func issuerNameMatches(name string, issuer *ctx509.Certificate) bool {
+	var cn, org string
+	for _, attr := range strings.Split(name, ", ") {
+		switch {
+		case strings.HasPrefix(attr, "CN="):
+			cn = strings.TrimPrefix(attr, "CN=")
+		case strings.HasPrefix(attr, "O="):
+			org = strings.TrimPrefix(attr, "O=")
+		}
+	}
+
+	wantOrg := ""
+	if len(issuer.Subject.Organization) > 0 {
+		wantOrg = issuer.Subject.Organization[0]
+	}
+	return cn == issuer.Subject.CommonName && org == wantOrg
+}
+
+// parseMonitorTime parses a crt.sh timestamp, in UTC without a zone. Unparseable times are zero.
+func parseMonitorTime(s string) time.Time {
+	t, err := time.Parse("2006-01-02T15:04:05", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MonitorCertResult is the outcome of re-verifying a certificate listed by a CT monitor.
+type MonitorCertResult struct {
+	// ID is the monitor's identifier for the certificate.
+	ID int64
+	// Result holds the outcome of verifying the certificate's embedded SCTs, if it was checked.
+	Result *Result
+	// Err is set if the certificate could not be checked, e.g. because it could not be fetched,
+	// its issuer is unknown, or it is a precertificate.
+	Err error
+}
+
+// CheckMonitorRecords re-verifies the certificates in a CT monitor's JSON records using the
+// default checker. See (*checker).CheckMonitorRecords.
+func CheckMonitorRecords(ctx context.Context, records []byte) ([]MonitorCertResult, error) {
+	return GetDefaultChecker().CheckMonitorRecords(ctx, records)
+}
+
+// CheckMonitorRecords verifies the embedded SCTs of each certificate listed in records, a JSON
+// array in the format of crt.sh's JSON output, e.g. for all the certificates of a domain. Records
+// carrying the certificate in a base64 cert_der field are checked directly; the others are
+// downloaded by ID from the crt.sh-compatible monitor at Options.MonitorURL.
+//
+// Records do not carry issuer certificates: each certificate's issuer is looked up in the pool
+// filled by AddIssuer, by authority key ID. Precertificates, which crt.sh lists alongside final
+// certificates, carry no SCTs and are reported with ErrPrecertificateLeaf.
+func (c *checker) CheckMonitorRecords(ctx context.Context, records []byte) ([]MonitorCertResult, error) {
+	var entries []monitorEntry
+	if err := json.Unmarshal(records, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse monitor records: %v", err)
+	}
+
+	results := make([]MonitorCertResult, len(entries))
+	for i, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return results[:i], err
+		}
+		results[i].ID = e.ID
+
+		var cert *ctx509.Certificate
+		var err error
+		if len(e.CertDER) > 0 {
+			cert, err = parseCertificate(e.CertDER)
+		} else {
+			cert, err = c.fetchMonitorCertificate(ctx, e.ID)
+		}
+		if err != nil {
+			results[i].Err = fmt.Errorf("certificate %d: %v", e.ID, err)
+			continue
+		}
+		if cert.IsPrecertificate() {
+			results[i].Err = ErrPrecertificateLeaf
+			continue
+		}
+
+		issuer := c.issuerFor(ctx, []*ctx509.Certificate{cert})
+		if issuer == nil {
+			results[i].Err = fmt.Errorf("certificate %d: issuer %q not known, see AddIssuer", e.ID, cert.Issuer)
+			continue
+		}
+		results[i].Result, results[i].Err = c.checkEmbedded(cert, issuer)
+	}
+
+	return results, nil
+}
+
+// fetchMonitorCertificate downloads the certificate with the given ID from the monitor.
+func (c *checker) fetchMonitorCertificate(ctx context.Context, id int64) (*ctx509.Certificate, error) {
+	query := url.Values{}
+	query.Set("d", fmt.Sprint(id))
+	req, err := http.NewRequest(http.MethodGet, c.opts.monitorURL()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient(c.opts.userAgent()).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from monitor: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("monitor returned %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from monitor: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate in monitor response")
+	}
+	return parseCertificate(block.Bytes)
+}
@@ -0,0 +1,101 @@
+package sct
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+func TestFindLoggedCertificates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("serial") != "3039" || r.URL.Query().Get("output") != "json" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"id": 1, "issuer_name": "C=US, O=Test Org, CN=Test CA", "common_name": "example.com",
+			 "serial_number": "3039", "entry_timestamp": "2021-03-04T05:06:07.123",
+			 "not_before": "2021-03-04T00:00:00", "not_after": "2021-06-02T00:00:00"},
+			{"id": 2, "issuer_name": "C=US, O=Other Org, CN=Other CA", "common_name": "example.org",
+			 "serial_number": "3039", "entry_timestamp": "2021-03-04T05:06:07",
+			 "not_before": "2021-03-04T00:00:00", "not_after": "2021-06-02T00:00:00"}
+		]`)
+	}))
+	defer srv.Close()
+
+	c := newTestChecker()
+	c.opts.MonitorURL = srv.URL + "/"
+	issuer := &ctx509.Certificate{Subject: pkix.Name{CommonName: "Test CA", Organization: []string{"Test Org"}}}
+
+	certs, err := c.FindLoggedCertificates(context.Background(), big.NewInt(12345), issuer)
+	if err != nil {
+		t.Fatalf("FindLoggedCertificates: %v", err)
+	}
+	if len(certs) != 1 {
+		t.Fatalf("got %d certificates, want the one from the given issuer", len(certs))
+	}
+	want := time.Date(2021, 3, 4, 5, 6, 7, 123000000, time.UTC)
+	if got := certs[0]; got.ID != 1 || got.CommonName != "example.com" || !got.EntryTimestamp.Equal(want) {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := c.FindLoggedCertificates(context.Background(), big.NewInt(1), issuer); err == nil {
+		t.Error("FindLoggedCertificates ignored an error response from the monitor")
+	}
+}
+
+func TestCheckMonitorRecords(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	precert := ca.issuePrecert(t, leafTemplate())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("d") {
+		case "2":
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: precert.Raw})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestChecker(l)
+	c.opts.MonitorURL = srv.URL + "/"
+	issuer, err := ConvertCert(ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddIssuer(issuer)
+
+	records := fmt.Sprintf(`[{"id": 1, "cert_der": %q}, {"id": 2}, {"id": 3}]`, base64.StdEncoding.EncodeToString(leaf.Raw))
+	results, err := c.CheckMonitorRecords(context.Background(), []byte(records))
+	if err != nil {
+		t.Fatalf("CheckMonitorRecords: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if r := results[0]; r.ID != 1 || r.Err != nil || r.Result.ValidCount() != 1 {
+		t.Errorf("certificate in record: got %+v, want 1 valid SCT", r)
+	}
+	if r := results[1]; r.ID != 2 || r.Err != ErrPrecertificateLeaf {
+		t.Errorf("downloaded precertificate: got %+v, want %v", r, ErrPrecertificateLeaf)
+	}
+	if r := results[2]; r.ID != 3 || r.Err == nil {
+		t.Errorf("certificate missing from the monitor: got %+v, want an error", r)
+	}
+
+	if _, err := c.CheckMonitorRecords(context.Background(), []byte("not json")); err == nil {
+		t.Error("CheckMonitorRecords accepted malformed records")
+	}
+}
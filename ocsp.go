@@ -0,0 +1,46 @@
+package sct
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// oidExtensionCTOCSP is the OCSP single response extension carrying an SCT list (RFC 6962 s3.3).
+var oidExtensionCTOCSP = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+
+// parseOCSPSCTs returns the SCTs in the RFC 6962 extension of the leaf's status in a DER OCSP
+// response, or nil if that status has no such extension. Statuses for other certificates are
+// ignored: an SCT list attached to them does not cover the leaf. The response signature is not
+// checked, as each SCT is signed by its log. A response signed by a delegated responder carries
+// the responder's certificate; it only serves to parse the response, and the SCTs are verified
+// against the leaf's chain as for any OCSP response.
+func parseOCSPSCTs(response []byte, leaf *x509.Certificate) ([][]byte, error) {
+	resp, err := ocsp.ParseResponseForCert(response, leaf, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %v", err)
+	}
+
+	for _, ext := range resp.Extensions {
+		if !ext.Id.Equal(oidExtensionCTOCSP) {
+			continue
+		}
+
+		var list []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil, fmt.Errorf("malformed SCT list extension in OCSP response: %v", err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("malformed SCT list extension in OCSP response: %d trailing bytes", len(rest))
+		}
+
+		if len(list) == 0 {
+			// An empty OCTET STRING: an empty list rather than an absent extension.
+			return [][]byte{}, nil
+		}
+		return parseSCTList(list)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,192 @@
+package sct
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// CheckCertFile verifies the SCTs embedded in a certificate on disk using the default checker.
+// See (*checker).CheckCertFile.
+func CheckCertFile(leafPath, issuerPath string) (*Result, error) {
+	return GetDefaultChecker().CheckCertFile(leafPath, issuerPath)
+}
+
+// CheckCertFile verifies the SCTs embedded in the certificate in the PEM file at leafPath,
+// issued by the certificate in the PEM file at issuerPath. Only the first certificate in
+// each file is used.
+func (c *checker) CheckCertFile(leafPath, issuerPath string) (*Result, error) {
+	leaf, err := readPEMCertificate(leafPath)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := readPEMCertificate(issuerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.checkEmbedded(leaf, issuer)
+}
+
+// CheckBase64Cert verifies the SCTs embedded in a base64 DER certificate using the default
+// checker. See (*checker).CheckBase64Cert.
+func CheckBase64Cert(leafB64, issuerB64 string) (*Result, error) {
+	return GetDefaultChecker().CheckBase64Cert(leafB64, issuerB64)
+}
+
+// CheckBase64Cert verifies the SCTs embedded in the base64 DER certificate leafB64, issued by
+// the base64 DER certificate issuerB64. Both the standard and URL-safe alphabets are accepted,
+// with or without padding.
+func (c *checker) CheckBase64Cert(leafB64, issuerB64 string) (*Result, error) {
+	leaf, err := parseBase64Certificate("leaf", leafB64)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := parseBase64Certificate("issuer", issuerB64)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.checkEmbedded(leaf, issuer)
+}
+
+// parseBase64Certificate decodes and parses the base64 DER certificate s, named name in errors.
+func parseBase64Certificate(name, s string) (*ctx509.Certificate, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if der, err = enc.DecodeString(s); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s certificate: %v", name, err)
+	}
+
+	cert, err := parseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s certificate: %v", name, err)
+	}
+	return cert, nil
+}
+
+// checkEmbedded verifies the SCTs embedded in leaf, which must have been issued by issuer.
+func (c *checker) checkEmbedded(leaf, issuer *ctx509.Certificate) (*Result, error) {
+	if err := checkIssuer(leaf, issuer); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		SCTs:       c.verifyEmbeddedSCTs(nil, leaf, issuer),
+		Validity:   certValidity(leaf, c.opts.now()),
+		ShortLived: IsShortLived(leaf),
+		Warnings:   embeddedSCTWarnings(leaf),
+	}
+	setIssuanceDelays(result.SCTs, leaf)
+	return result, nil
+}
+
+// CheckPEMChain verifies the SCTs of a PEM certificate bundle using the default checker.
+// See (*checker).CheckPEMChain.
+func CheckPEMChain(pemChain string, tlsSCTs [][]byte) (*Result, error) {
+	return GetDefaultChecker().CheckPEMChain(pemChain, tlsSCTs)
+}
+
+// CheckPEMChain verifies the SCTs of the certificates in pemChain, as CheckConnectionStateDetailed
+// would for a connection which presented them along with tlsSCTs in the TLS extension. The
+// certificates may be in any order: the chain is rebuilt from the leaf by following issuer names.
+// Blocks other than certificates, such as private keys, are skipped.
+func (c *checker) CheckPEMChain(pemChain string, tlsSCTs [][]byte) (*Result, error) {
+	certs, err := parsePEMCertificates([]byte(pemChain))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates:            orderChain(certs),
+		SignedCertificateTimestamps: tlsSCTs,
+	})
+}
+
+// parsePEMCertificates parses every certificate in data, skipping any other blocks.
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %v", len(certs), err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// orderChain returns certs ordered leaf first, each followed by its issuer. The leaf is the first
+// certificate which issued none of the others. Certificates not on the leaf's issuer path are
+// kept, in their original order, after it.
+func orderChain(certs []*x509.Certificate) []*x509.Certificate {
+	issuedOther := func(cert *x509.Certificate) bool {
+		for _, other := range certs {
+			if other != cert && bytes.Equal(other.RawIssuer, cert.RawSubject) {
+				return true
+			}
+		}
+		return false
+	}
+
+	leaf := certs[0]
+	for _, cert := range certs {
+		if !issuedOther(cert) {
+			leaf = cert
+			break
+		}
+	}
+
+	used := map[*x509.Certificate]bool{leaf: true}
+	ordered := []*x509.Certificate{leaf}
+	for current := leaf; ; {
+		var next *x509.Certificate
+		for _, cert := range certs {
+			if !used[cert] && bytes.Equal(current.RawIssuer, cert.RawSubject) {
+				next = cert
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		used[next] = true
+		ordered = append(ordered, next)
+		current = next
+	}
+
+	for _, cert := range certs {
+		if !used[cert] {
+			ordered = append(ordered, cert)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,116 @@
+package sct
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, dir, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, pem.EncodeToMemory(b)...)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func certBlock(cert *x509.Certificate) *pem.Block {
+	return &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+}
+
+func TestCheckCertFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zsct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	// A key and a second certificate after the leaf: only the first certificate is used.
+	leafPath := writePEM(t, dir, "leaf.pem",
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{0x00}}, certBlock(leaf), certBlock(other.cert))
+	issuerPath := writePEM(t, dir, "issuer.pem", certBlock(ca.cert))
+	c := newTestChecker(l)
+
+	result, err := c.CheckCertFile(leafPath, issuerPath)
+	if err != nil {
+		t.Fatalf("CheckCertFile: %v", err)
+	}
+	if len(result.SCTs) != 1 || result.ValidCount() != 1 {
+		t.Errorf("got %d SCTs, %d valid; want 1 valid", len(result.SCTs), result.ValidCount())
+	}
+
+	wrongIssuerPath := writePEM(t, dir, "wrong.pem", certBlock(other.cert))
+	if _, err := c.CheckCertFile(leafPath, wrongIssuerPath); err == nil {
+		t.Error("CheckCertFile succeeded with the wrong issuer")
+	}
+}
+
+func TestCheckBase64Cert(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawURLEncoding} {
+		result, err := c.CheckBase64Cert(enc.EncodeToString(leaf.Raw), enc.EncodeToString(ca.cert.Raw)+"\n")
+		if err != nil {
+			t.Fatalf("CheckBase64Cert: %v", err)
+		}
+		if result.ValidCount() != 1 {
+			t.Errorf("got %d valid SCTs, want 1", result.ValidCount())
+		}
+	}
+
+	issuerB64 := base64.StdEncoding.EncodeToString(ca.cert.Raw)
+	if _, err := c.CheckBase64Cert("not base64!", issuerB64); err == nil || !strings.Contains(err.Error(), "leaf") {
+		t.Errorf("CheckBase64Cert(invalid base64) = %v, want a leaf decoding error", err)
+	}
+	if _, err := c.CheckBase64Cert(base64.StdEncoding.EncodeToString(leaf.Raw), base64.StdEncoding.EncodeToString([]byte("not DER"))); err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Errorf("CheckBase64Cert(invalid DER) = %v, want an issuer parsing error", err)
+	}
+	if _, err := c.CheckBase64Cert(base64.StdEncoding.EncodeToString(leaf.Raw), base64.StdEncoding.EncodeToString(newTestCA(t).cert.Raw)); err == nil {
+		t.Error("CheckBase64Cert succeeded with the wrong issuer")
+	}
+}
+
+func TestCheckPEMChain(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	tlsSCT := marshalSCT(t, l.sign(t, x509Leaf(t, mustBuildChain(t, leaf, ca.cert)), time.Now()))
+	c := newTestChecker(l)
+
+	// Issuer first, with a key block in between: the chain is reordered and the key skipped.
+	var bundle []byte
+	for _, b := range []*pem.Block{certBlock(ca.cert), {Type: "EC PRIVATE KEY", Bytes: []byte{0x00}}, certBlock(leaf)} {
+		bundle = append(bundle, pem.EncodeToMemory(b)...)
+	}
+
+	result, err := c.CheckPEMChain(string(bundle), [][]byte{tlsSCT})
+	if err != nil {
+		t.Fatalf("CheckPEMChain: %v", err)
+	}
+	if result.ValidCount() != 1 {
+		t.Errorf("got %d valid SCTs, want 1", result.ValidCount())
+	}
+
+	if _, err := c.CheckPEMChain("not a certificate", [][]byte{tlsSCT}); err == nil {
+		t.Error("CheckPEMChain accepted a bundle without certificates")
+	}
+}
@@ -0,0 +1,104 @@
+package sct
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CheckAgainstOperators verifies SCTs from the named operators' logs using the default checker.
+// See (*checker).CheckAgainstOperators.
+func CheckAgainstOperators(state *tls.ConnectionState, operators []string) (*Result, error) {
+	return GetDefaultChecker().CheckAgainstOperators(state, operators)
+}
+
+// CheckAgainstOperators is like CheckConnectionStateDetailed, but only verifies SCTs from logs
+// run by the named operators (as named in the log list). SCTs from other operators' logs, and
+// from logs missing from the log list, which cannot be attributed to any operator, are skipped
+// rather than failed, and left out of the result.
+func (c *checker) CheckAgainstOperators(state *tls.ConnectionState, operators []string) (*Result, error) {
+	p := &checkParams{operators: make(map[string]bool, len(operators))}
+	for _, op := range operators {
+		p.operators[op] = true
+	}
+
+	return c.checkConnectionStateDetailed(p, state)
+}
+
+// PreviousOperator is an operator which ran a log before its current one, as listed in the
+// "previous_operators" field of a log in a v3 log list.
+type PreviousOperator struct {
+	Name string `json:"name"`
+	// EndTime is when the operator stopped running the log.
+	EndTime time.Time `json:"end_time"`
+}
+
+// ParsePreviousOperators returns the previous operators of each log in a JSON log list, by
+// hex-encoded KeyID, for Options.PreviousOperators. The log list parser used by the checker
+// predates the field and drops it. Logs without previous operators are left out.
+func ParsePreviousOperators(jsonData []byte) (map[string][]PreviousOperator, error) {
+	var ll struct {
+		Operators []struct {
+			Logs []struct {
+				LogID             []byte             `json:"log_id"`
+				PreviousOperators []PreviousOperator `json:"previous_operators"`
+			} `json:"logs"`
+		} `json:"operators"`
+	}
+	if err := json.Unmarshal(jsonData, &ll); err != nil {
+		return nil, fmt.Errorf("failed to parse log list: %v", err)
+	}
+
+	history := make(map[string][]PreviousOperator)
+	for _, op := range ll.Operators {
+		for _, l := range op.Logs {
+			if len(l.PreviousOperators) > 0 {
+				history[hex.EncodeToString(l.LogID)] = l.PreviousOperators
+			}
+		}
+	}
+	return history, nil
+}
+
+// operatorAtIssuance returns the operator of the log that issued s when it issued it: the
+// previous operator whose tenure covers the SCT's timestamp, per Options.PreviousOperators, or
+// the log's current operator.
+func (c *checker) operatorAtIssuance(s *SCTResult) string {
+	operator := s.Operator
+	var end time.Time
+	for _, prev := range c.opts.PreviousOperators[s.LogID] {
+		// The earliest tenure ending after the SCT was issued.
+		if s.Timestamp.Before(prev.EndTime) && (end.IsZero() || prev.EndTime.Before(end)) {
+			operator, end = prev.Name, prev.EndTime
+		}
+	}
+	return operator
+}
+
+// MissingOperators returns the operators absent from result using the default checker.
+// See (*checker).MissingOperators.
+func MissingOperators(result *Result) []string {
+	return GetDefaultChecker().MissingOperators(result)
+}
+
+// MissingOperators returns the names of the log list's operators none of whose logs issued an
+// SCT in result, in log list order, e.g. to study which operators a CA does not submit to. SCTs
+// count whether or not they verified; SCTs from logs missing from the log list are ignored.
+func (c *checker) MissingOperators(result *Result) []string {
+	present := make(map[string]bool)
+	for _, s := range result.SCTs {
+		if s.Operator != "" {
+			present[s.Operator] = true
+		}
+	}
+
+	var missing []string
+	for _, op := range c.logList().Operators {
+		if !present[op.Name] {
+			missing = append(missing, op.Name)
+		}
+	}
+	return missing
+}
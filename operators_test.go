@@ -0,0 +1,101 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckAgainstOperators(t *testing.T) {
+	selected := newTestLog(t, "Selected Log")
+	other := newTestLog(t, "Other Log")
+	unknown := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), selected, other)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, unknown.sign(t, merkleLeaf, time.Now()))},
+	}
+	// Operator 0 runs the selected log, operator 1 the other one.
+	c := newMultiOperatorChecker(selected, other)
+
+	result, err := c.CheckAgainstOperators(state, []string{"Operator 0"})
+	if err != nil {
+		t.Fatalf("CheckAgainstOperators: %v", err)
+	}
+	if len(result.SCTs) != 1 {
+		t.Fatalf("got %d SCT results, want the selected operator's only", len(result.SCTs))
+	}
+	if s := result.SCTs[0]; s.LogDescription != "Selected Log" || !s.Valid() {
+		t.Errorf("got SCT from log %q (error %v), want a valid SCT from the selected operator", s.LogDescription, s.Err)
+	}
+}
+
+func TestOperatorAtIssuance(t *testing.T) {
+	logA := newTestLog(t, "Log A")
+	logB := newTestLog(t, "Log B")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	precert := ca.precertLeaf(t, tmpl)
+
+	// Log B moved from operator 0, which also runs log A, to operator 1 an hour ago.
+	c := newMultiOperatorChecker(logA, logB)
+	c.opts.WarnOnInclusionFailure = true
+	moved := time.Now().Add(-time.Hour)
+	c.opts.PreviousOperators = map[string][]PreviousOperator{
+		hex.EncodeToString(logB.log.LogID): {{Name: "Operator 0", EndTime: moved}},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		issued        time.Time
+		wantViolation bool
+	}{
+		{"before move", moved.Add(-time.Minute), true},
+		{"after move", moved.Add(time.Minute), false},
+	} {
+		leaf := ca.issue(t, tmpl, logA.sign(t, precert, moved.Add(-time.Minute)), logB.sign(t, precert, tc.issued))
+		report := c.ComplianceReport(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}})
+		violations := strings.Join(report.Violations, "\n")
+		if got := strings.Contains(violations, "operator diversity"); got != tc.wantViolation {
+			t.Errorf("%s: operator diversity violation = %v, want %v (violations %q)", tc.name, got, tc.wantViolation, report.Violations)
+		}
+	}
+}
+
+func TestParsePreviousOperators(t *testing.T) {
+	history, err := ParsePreviousOperators([]byte(`{"operators": [{"name": "New", "logs": [
+		{"log_id": "AAEC", "previous_operators": [{"name": "Old", "end_time": "2024-01-02T00:00:00Z"}]},
+		{"log_id": "AwQF"}
+	]}]}`))
+	if err != nil {
+		t.Fatalf("ParsePreviousOperators: %v", err)
+	}
+	want := []PreviousOperator{{Name: "Old", EndTime: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}
+	if len(history) != 1 || len(history["000102"]) != 1 || history["000102"][0] != want[0] {
+		t.Errorf("ParsePreviousOperators = %v, want only log 000102 with %v", history, want)
+	}
+}
+
+func TestMissingOperators(t *testing.T) {
+	logA := newTestLog(t, "Log A")
+	logB := newTestLog(t, "Log B")
+	logC := newTestLog(t, "Log C")
+	unknown := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), logB, unknown)
+	c := newMultiOperatorChecker(logA, logB, logC)
+
+	result, err := c.CheckConnectionStateDetailed(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	got := strings.Join(c.MissingOperators(result), ", ")
+	if want := "Operator 0, Operator 2"; got != want {
+		t.Errorf("MissingOperators = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,329 @@
+package sct
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+// DefaultMaxClockSkew is the default for Options.MaxClockSkew.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// DefaultEntryNotFoundGrace is the default for Options.EntryNotFoundGrace.
+const DefaultEntryNotFoundGrace = time.Hour
+
+// Options configures a checker created with NewChecker.
+// The zero value gives the same behavior as the default checker.
+type Options struct {
+	// RequireInclusion rejects SCTs whose inclusion in the log cannot be proven.
+	// By default, such SCTs are accepted while they are younger than the log's Maximum Merge Delay.
+	RequireInclusion bool
+
+	// RequireLogStateAtIssuance rejects SCTs issued while their log was not accepting
+	// submissions, e.g. before it was qualified or after it became read-only or retired.
+	RequireLogStateAtIssuance bool
+
+	// OnlyUsableLogs rejects SCTs from logs which are not currently usable, e.g. retired or
+	// read-only, whenever the SCTs were issued: such SCTs no longer count for new certificates.
+	// Unlike RequireLogStateAtIssuance, it considers the log's state at the time of the check.
+	OnlyUsableLogs bool
+
+	// AcceptPendingLogs counts SCTs from logs in the pending state, still applying for inclusion
+	// in the log list, as valid. They are rejected by default, and by RequireLogStateAtIssuance
+	// and OnlyUsableLogs. Either way, SCTResult.LogStatus records that the log is pending, and
+	// accepted SCTs carry a warning saying so. Log lists fetched by the checker only keep
+	// qualified, usable and read-only logs: pending logs come from lists given to NewChecker.
+	AcceptPendingLogs bool
+
+	// MaxLogMMD, if set, rejects SCTs from logs whose declared Maximum Merge Delay exceeds it,
+	// e.g. to require that entries are merged within 12 hours although logs may take 24.
+	MaxLogMMD time.Duration
+
+	// RejectUnknownVersions rejects SCTs with a version other than v1.
+	RejectUnknownVersions bool
+
+	// RejectExtensions rejects SCTs with non-empty extensions, since RFC 6962 defines none.
+	RejectExtensions bool
+
+	// RequireEmbedded makes CheckConnectionState fail unless the leaf embeds valid SCTs from at
+	// least as many distinct logs as the CT policy requires for its lifetime (see ComplianceReport),
+	// whatever SCTs are delivered via TLS or OCSP.
+	RequireEmbedded bool
+
+	// StrictRFC6962 enables RequireInclusion, RequireLogStateAtIssuance, RejectUnknownVersions and
+	// RejectExtensions, so that SCTs are verified exactly per the RFC, with no shortcuts. The other
+	// options above are left as set.
+	StrictRFC6962 bool
+
+	// WarnOnInclusionFailure accepts SCTs whose signature is valid but whose inclusion in the log
+	// cannot be proven, even past the log's Maximum Merge Delay, recording a warning on the SCT's
+	// result instead. It has no effect when inclusion is required.
+	WarnOnInclusionFailure bool
+
+	// FailOnEntryNotFound rejects SCTs whose log answers get-proof-by-hash with 404, i.e. that it
+	// holds no such entry, once the SCT is older than EntryNotFoundGrace, even within the log's
+	// Maximum Merge Delay or with WarnOnInclusionFailure. Other inclusion failures, such as
+	// timeouts, are still tolerated. Since the get-entries fallback treats 404 as the endpoint
+	// being unavailable, this has no effect with InclusionFallbackGetEntries.
+	FailOnEntryNotFound bool
+	// EntryNotFoundGrace is how long a log may take to serve a proof for a new entry, see
+	// FailOnEntryNotFound. It defaults to DefaultEntryNotFoundGrace.
+	EntryNotFoundGrace time.Duration
+
+	// RecentSCTGrace is how old an SCT may be before failing to prove its inclusion rejects it,
+	// instead of its log's MMD. A negative value exempts no SCT, like RequireInclusion, while zero
+	// keeps each log's MMD.
+	RecentSCTGrace time.Duration
+
+	// InclusionFallbackGetEntries proves inclusion for logs which do not serve get-proof-by-hash
+	// by locating the entry with get-entries, then fetching its proof with get-entry-and-proof.
+	// This takes many requests per SCT, so it is only attempted when get-proof-by-hash answers
+	// 404, 405 or 501.
+	InclusionFallbackGetEntries bool
+
+	// InclusionVerifier hashes log entries and verifies inclusion proofs, e.g. to experiment with
+	// logs using other Merkle tree constructions. It defaults to RFC6962InclusionVerifier.
+	// Consistency proofs, see PinnedSTHs, are always verified as RFC 6962 specifies.
+	InclusionVerifier InclusionVerifier
+
+	// PinnedSTHs holds an STH previously observed from each log, by hex-encoded KeyID. When
+	// verifying inclusion in a log with a pinned STH, the checker also verifies a consistency
+	// proof from the pinned STH to the log's current one, showing that the log only appended
+	// entries in between. An inconsistency fails the SCT with a LogConsistencyError, whatever
+	// the other inclusion options.
+	PinnedSTHs map[string]*ct.SignedTreeHead
+
+	// RecordInclusionSTH makes detailed checks record, for each SCT whose inclusion was proven,
+	// the STH the proof was verified against in SCTResult.InclusionSTH.
+	RecordInclusionSTH bool
+
+	// VerifyIssuerSignature checks that the issuer's key signed the leaf before verifying the
+	// leaf's embedded SCTs, which are signed over the issuer's key hash. A chain assembled with
+	// the wrong issuer then fails with that cause, rather than with every SCT's signature.
+	VerifyIssuerSignature bool
+
+	// AllowNoSCTsForPrivateRoots exempts certificates of internal PKIs from CT: a certificate
+	// without any SCT which chains to one of PrivateRoots is reported as not applicable
+	// (ErrCTNotApplicable, or Result.NotApplicable) rather than as failing the check.
+	AllowNoSCTsForPrivateRoots bool
+	// PrivateRoots holds the roots of the internal PKIs exempted by AllowNoSCTsForPrivateRoots.
+	// It has no effect without it.
+	PrivateRoots *x509.CertPool
+
+	// PreviousOperators lists, by hex-encoded KeyID, the operators which ran a log before its
+	// current one, e.g. from ParsePreviousOperators. Operator diversity in compliance reports then
+	// counts the operator running the log when each SCT was issued.
+	PreviousOperators map[string][]PreviousOperator
+
+	// LogListURL, LogListSigURL and LogListPubKeyURL are the sources used by RefreshLogList:
+	// a log list, its signature and the PEM public key it is signed with. Each may be a URL or
+	// a file path, and defaults to Google's log list.
+	LogListURL       string
+	LogListSigURL    string
+	LogListPubKeyURL string
+
+	// OnLogListChange, if set, is called by RefreshLogList after it replaces the log list, with
+	// the logs added and removed by the new list, identified by KeyID. It is not called when the
+	// set of logs is unchanged, e.g. when only log states changed. It runs on the refreshing
+	// goroutine, so it should not block.
+	OnLogListChange func(added, removed []*loglist2.Log)
+
+	// OnLogExchange, if set, is called with every request made to a log, e.g. for STHs, inclusion
+	// proofs or entries, along with the raw response, to diagnose log-specific failures. It is
+	// called on the verifying goroutine, and concurrently when checks run in parallel.
+	OnLogExchange func(*LogExchange)
+
+	// LogURLOverrides maps a log's hex-encoded KeyID to a mirror of that log. Requests for
+	// inclusion proofs then go to the mirror instead of the URL in the log list, while SCTs
+	// and proofs are still verified against the log's key from the log list.
+	LogURLOverrides map[string]string
+
+	// LogRateLimit bounds the rate of requests to each log, with a separate budget per log.
+	// LogRateLimits overrides it for individual logs, by hex-encoded KeyID.
+	LogRateLimit  RateLimit
+	LogRateLimits map[string]RateLimit
+
+	// MonitorURL is the crt.sh-compatible CT monitor queried by FindLoggedCertificates.
+	// It defaults to crt.sh.
+	MonitorURL string
+
+	// LogDiscoveryURL, if set, is queried about the logs of SCTs whose KeyID is not in the log
+	// list, with a GET request carrying the hex-encoded KeyID in the log_id parameter. The service
+	// answers 404 for logs it does not know, or 200 with a JSON object describing the log in the
+	// log list format: description, url and base64 DER key. The SCT is still rejected, but its
+	// result records the discovered log. Answers are cached, those about unknown logs for an
+	// hour.
+	LogDiscoveryURL string
+
+	// MaxClockSkew is how far in the future an SCT's timestamp may be before the SCT is
+	// rejected, to tolerate clocks that differ between the log and the checker. It defaults
+	// to DefaultMaxClockSkew.
+	MaxClockSkew time.Duration
+
+	// WarmUpFetchSTH makes WarmUp fetch each log's current STH as well as build its client.
+	WarmUpFetchSTH bool
+
+	// MaxSCTsToCheck bounds the number of SCTs verified from each source (TLS extension, OCSP
+	// response, certificate), to limit the work done on certificates or handshakes carrying
+	// pathologically many SCTs. If no SCT among the first MaxSCTsToCheck is valid, the source
+	// fails. Detailed checks report the SCTs beyond the limit as failed, without verifying
+	// them. Zero means no limit.
+	MaxSCTsToCheck int
+
+	// RecordPhaseTimings makes detailed checks record the time spent in each verification phase
+	// in Result.Timings. Without it, no time is measured.
+	RecordPhaseTimings bool
+
+	// OnProgress, if set, is called by CheckHosts, CheckConnectionStates and CheckEmbeddedBatch
+	// every ProgressInterval completed checks, and once more at the end of the batch, with the
+	// counts so far, e.g. to render a progress bar. Calls are made one at a time, even though
+	// checks run concurrently, and should not block. ProgressInterval defaults to 1.
+	OnProgress       func(Progress)
+	ProgressInterval int
+
+	// ResultStore, if set, is given the result of each check made by CheckHosts and
+	// CheckConnectionStates, by host, e.g. to accumulate the results of a long-running monitor.
+	ResultStore ResultStore
+
+	// FetchAIAIssuers makes the checker download the issuer of a leaf whose chain lacks it, and
+	// which is not in the issuer pool (see AddIssuer), from the caIssuers URLs of the leaf's
+	// authority information access extension. Downloaded issuers are cached by URL, but never
+	// added to the pool. Only http and https URLs are fetched, unless AIAExtendedSchemes is set.
+	FetchAIAIssuers bool
+	// AIAExtendedSchemes also allows file URLs, read from the local file system, and ldap and
+	// ldaps URLs, fetched with LDAPFetch, as used by some enterprise PKIs.
+	AIAExtendedSchemes bool
+	// LDAPFetch returns the certificate, DER or PEM encoded, at an ldap or ldaps AIA URL. This
+	// package has no LDAP client of its own, so ldap URLs are skipped without it.
+	LDAPFetch func(ctx context.Context, url string) ([]byte, error)
+
+	// MaxCertificateBytes, MaxSCTListBytes and MaxOCSPResponseBytes bound the size of each DER
+	// certificate, TLS-encoded SCT list and OCSP response a check accepts, so that untrusted
+	// inputs cannot make the checker allocate without bound. Larger inputs are rejected with an
+	// InputLimitError before being parsed. Zero means the defaults, e.g. DefaultMaxCertificateBytes,
+	// and a negative value no limit.
+	MaxCertificateBytes  int
+	MaxSCTListBytes      int
+	MaxOCSPResponseBytes int
+
+	// Now returns the current time, for SCT ages and certificate validity. It defaults to time.Now.
+	Now func() time.Time
+
+	// UserAgent is sent with every request to logs and log list sources. It defaults to
+	// zsct/<version>; setting it to identify the caller is good etiquette when scanning at volume.
+	UserAgent string
+}
+
+func (o *Options) logListURL() string {
+	if o.LogListURL != "" {
+		return o.LogListURL
+	}
+	return logListURL
+}
+
+func (o *Options) logListSigURL() string {
+	if o.LogListSigURL != "" {
+		return o.LogListSigURL
+	}
+	return logListSigURL
+}
+
+func (o *Options) logListPubKeyURL() string {
+	if o.LogListPubKeyURL != "" {
+		return o.LogListPubKeyURL
+	}
+	return logListPubKeyURL
+}
+
+func (o *Options) logURL(ctLog *loglist2.Log) string {
+	if url, ok := o.LogURLOverrides[hex.EncodeToString(ctLog.LogID)]; ok {
+		return url
+	}
+	return ctLog.URL
+}
+
+func (o *Options) logRateLimit(ctLog *loglist2.Log) RateLimit {
+	if l, ok := o.LogRateLimits[hex.EncodeToString(ctLog.LogID)]; ok {
+		return l
+	}
+	return o.LogRateLimit
+}
+
+func (o *Options) monitorURL() string {
+	if o.MonitorURL != "" {
+		return o.MonitorURL
+	}
+	return defaultMonitorURL
+}
+
+func (o *Options) maxClockSkew() time.Duration {
+	if o.MaxClockSkew > 0 {
+		return o.MaxClockSkew
+	}
+	return DefaultMaxClockSkew
+}
+
+func (o *Options) entryNotFoundGrace() time.Duration {
+	if o.EntryNotFoundGrace > 0 {
+		return o.EntryNotFoundGrace
+	}
+	return DefaultEntryNotFoundGrace
+}
+
+// recentSCTGrace returns how old an SCT from a log with the given MMD may be without a proof
+// of inclusion.
+func (o *Options) recentSCTGrace(mmd time.Duration) time.Duration {
+	if o.RecentSCTGrace != 0 {
+		return o.RecentSCTGrace
+	}
+	return mmd
+}
+
+func (o *Options) inclusionVerifier() InclusionVerifier {
+	if o.InclusionVerifier != nil {
+		return o.InclusionVerifier
+	}
+	return RFC6962InclusionVerifier{}
+}
+
+// sctsToCheck returns how many of n SCTs from one source may be verified.
+func (o *Options) sctsToCheck(n int) int {
+	if o.MaxSCTsToCheck > 0 && n > o.MaxSCTsToCheck {
+		return o.MaxSCTsToCheck
+	}
+	return n
+}
+
+func (o *Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+func (o *Options) userAgent() string {
+	if o.UserAgent != "" {
+		return o.UserAgent
+	}
+	return defaultUserAgent
+}
+
+func (o *Options) requireInclusion() bool {
+	return o.RequireInclusion || o.StrictRFC6962
+}
+
+func (o *Options) requireLogStateAtIssuance() bool {
+	return o.RequireLogStateAtIssuance || o.StrictRFC6962
+}
+
+func (o *Options) rejectUnknownVersions() bool {
+	return o.RejectUnknownVersions || o.StrictRFC6962
+}
+
+func (o *Options) rejectExtensions() bool {
+	return o.RejectExtensions || o.StrictRFC6962
+}
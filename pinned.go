@@ -0,0 +1,88 @@
+package sct
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+// defaultPinnedMMD is the Maximum Merge Delay assumed for pinned logs that do not set one.
+const defaultPinnedMMD = 24 * time.Hour
+
+// pinnedOperator is the operator name given to every log of a checker made by NewPinnedChecker.
+const pinnedOperator = "Pinned"
+
+// ErrPinnedLogList is returned by RefreshLogList for checkers made by NewPinnedChecker, which
+// trust their pinned logs only.
+var ErrPinnedLogList = errors.New("log list is pinned and cannot be refreshed")
+
+// PinnedLog describes a log trusted by NewPinnedChecker beyond its public key.
+type PinnedLog struct {
+	// Description names the log in results. It defaults to its hex-encoded KeyID.
+	Description string
+	// URL is the log's base URL, used to prove inclusion. Without one, SCTs are only accepted
+	// while younger than the MMD, or with Options.WarnOnInclusionFailure.
+	URL string
+	// MMD is the log's Maximum Merge Delay. It defaults to 24 hours.
+	MMD time.Duration
+}
+
+// NewPinnedChecker returns a checker trusting exactly the logs whose public keys are in keys, by
+// KeyID (the SHA-256 hash of the key's DER encoding), without any log list. logs optionally
+// describes each of them further. Every pinned log is treated as usable and run by one operator
+// named "Pinned", so policies requiring distinct operators cannot be met.
+//
+// The pinned logs are held in a log list built from the keys, which RefreshLogList refuses to
+// replace with ErrPinnedLogList.
+func NewPinnedChecker(keys map[[sha256.Size]byte]crypto.PublicKey, logs map[[sha256.Size]byte]PinnedLog, opts Options) (*checker, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no pinned log keys")
+	}
+
+	// Map iteration order is random: sort by KeyID for a stable log list.
+	keyIDs := make([][sha256.Size]byte, 0, len(keys))
+	for keyID := range keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Slice(keyIDs, func(i, j int) bool { return bytes.Compare(keyIDs[i][:], keyIDs[j][:]) < 0 })
+
+	var pinned []*loglist2.Log
+	for _, keyID := range keyIDs {
+		der, err := x509.MarshalPKIXPublicKey(keys[keyID])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal public key for log %x: %v", keyID, err)
+		}
+		if sha256.Sum256(der) != keyID {
+			return nil, fmt.Errorf("public key does not match KeyID %x", keyID)
+		}
+
+		info := logs[keyID]
+		if info.Description == "" {
+			info.Description = hex.EncodeToString(keyID[:])
+		}
+		if info.MMD == 0 {
+			info.MMD = defaultPinnedMMD
+		}
+
+		ctLog, err := NewLocalLog(info.Description, info.URL, der, info.MMD)
+		if err != nil {
+			return nil, err
+		}
+		pinned = append(pinned, ctLog)
+	}
+
+	c, err := NewChecker(NewLocalLogList(pinnedOperator, pinned...), opts)
+	if err != nil {
+		return nil, err
+	}
+	c.pinned = true
+	return c, nil
+}
@@ -0,0 +1,75 @@
+package sct
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewPinnedChecker(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	other := newTestLog(t, "Other Log")
+	ca := newTestCA(t)
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, leafTemplate(), l), ca.cert},
+	}
+
+	var keyID [sha256.Size]byte
+	copy(keyID[:], l.log.LogID)
+	c, err := NewPinnedChecker(
+		map[[sha256.Size]byte]crypto.PublicKey{keyID: &l.key.PublicKey},
+		map[[sha256.Size]byte]PinnedLog{keyID: {Description: "Pinned Log", URL: "http://127.0.0.1:1/"}},
+		Options{},
+	)
+	if err != nil {
+		t.Fatalf("NewPinnedChecker: %v", err)
+	}
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 || result.SCTs[0].LogDescription != "Pinned Log" {
+		t.Errorf("got %d valid SCTs from %q, want 1 from %q", result.ValidCount(), result.SCTs[0].LogDescription, "Pinned Log")
+	}
+
+	c, err = NewPinnedChecker(map[[sha256.Size]byte]crypto.PublicKey{keyID: &other.key.PublicKey}, nil, Options{})
+	if err == nil {
+		t.Error("NewPinnedChecker accepted a key not matching its KeyID")
+	}
+
+	copy(keyID[:], other.log.LogID)
+	c, err = NewPinnedChecker(map[[sha256.Size]byte]crypto.PublicKey{keyID: &other.key.PublicKey}, nil, Options{})
+	if err != nil {
+		t.Fatalf("NewPinnedChecker: %v", err)
+	}
+	if err := c.CheckConnectionState(state); err == nil {
+		t.Error("CheckConnectionState accepted an SCT from a log that is not pinned")
+	}
+}
+
+func TestPinnedCheckerRefresh(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	var keyID [sha256.Size]byte
+	copy(keyID[:], l.log.LogID)
+	c, err := NewPinnedChecker(map[[sha256.Size]byte]crypto.PublicKey{keyID: &l.key.PublicKey}, nil, Options{})
+	if err != nil {
+		t.Fatalf("NewPinnedChecker: %v", err)
+	}
+
+	if err := c.RefreshLogList(); err != ErrPinnedLogList {
+		t.Errorf("RefreshLogList = %v, want %v", err, ErrPinnedLogList)
+	}
+	if len(c.logList().Operators) != 1 || c.logList().Operators[0].Name != pinnedOperator {
+		t.Errorf("pinned logs replaced by RefreshLogList")
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("/refresh status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,274 @@
+package sct
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist2"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// Chrome CT policy parameters, see https://googlechrome.github.io/CertificateTransparency/ct_policy.html.
+const (
+	// chromeShortLifetime is the longest certificate lifetime needing chromeShortLifetimeSCTs embedded SCTs.
+	chromeShortLifetime     = 180 * 24 * time.Hour
+	chromeShortLifetimeSCTs = 2
+	chromeLongLifetimeSCTs  = 3
+	// chromeDeliveredSCTs is the number of SCTs needed when delivered via TLS or OCSP.
+	chromeDeliveredSCTs = 2
+	chromeMinOperators  = 2
+)
+
+// Report lists every way in which a certificate fails the CT policy.
+type Report struct {
+	// Result holds the per-SCT outcomes the report is based on. It is nil if the
+	// connection state could not be examined.
+	Result *Result
+	// Violations is empty if the certificate is compliant.
+	Violations []string
+}
+
+// Compliant returns true if no violation was found. A report on a certificate CT does not apply
+// to (Result.NotApplicable) has no violations, but is not compliant either.
+func (r *Report) Compliant() bool {
+	return len(r.Violations) == 0 && (r.Result == nil || !r.Result.NotApplicable)
+}
+
+func (r *Report) addViolation(format string, args ...interface{}) {
+	r.Violations = append(r.Violations, fmt.Sprintf(format, args...))
+}
+
+// ComplianceReport evaluates the connection state against the CT policy using the default checker.
+// See (*checker).ComplianceReport.
+func ComplianceReport(state *tls.ConnectionState) *Report {
+	return GetDefaultChecker().ComplianceReport(state)
+}
+
+// ComplianceReport evaluates the connection state against every dimension of Chrome's CT policy
+// independently, and reports all violations rather than the first: too few valid SCTs, too few
+// distinct log operators, SCTs from retired logs and SCTs whose inclusion was not proven.
+// If CT does not apply to the certificate (Result.NotApplicable), the policy is not evaluated.
+func (c *checker) ComplianceReport(state *tls.ConnectionState) *Report {
+	report := &Report{}
+
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		report.addViolation("%v", err)
+		return report
+	}
+	report.Result = result
+	if result.NotApplicable {
+		return report
+	}
+
+	chain, err := BuildCertificateChain(state.PeerCertificates[:1])
+	if err != nil {
+		report.addViolation("%v", err)
+		return report
+	}
+	leaf := chain[0]
+
+	// Each log counts once, however many of its SCTs are presented.
+	embeddedLogs := make(map[string]bool)
+	deliveredLogs := make(map[string]bool)
+	operators := make(map[string]bool)
+	for _, s := range result.SCTs {
+		if s.LogStatus == loglist2.RetiredLogStatus {
+			report.addViolation("SCT from retired log %q", s.LogDescription)
+		}
+		if !s.Valid() {
+			continue
+		}
+		if !s.InclusionVerified {
+			report.addViolation("inclusion of SCT from log %q not proven", s.LogDescription)
+		}
+
+		if s.Source == SourceEmbedded {
+			embeddedLogs[s.LogID] = true
+		} else {
+			deliveredLogs[s.LogID] = true
+		}
+		operators[c.operatorAtIssuance(s)] = true
+	}
+
+	embeddedNeeded := requiredEmbeddedSCTs(leaf)
+	if len(embeddedLogs) < embeddedNeeded && len(deliveredLogs) < chromeDeliveredSCTs {
+		report.addViolation("too few valid SCTs: %d embedded (need %d), %d delivered via TLS or OCSP (need %d)",
+			len(embeddedLogs), embeddedNeeded, len(deliveredLogs), chromeDeliveredSCTs)
+	}
+
+	if len(operators) < chromeMinOperators {
+		report.addViolation("insufficient log operator diversity: valid SCTs from %d operator(s), need %d",
+			len(operators), chromeMinOperators)
+	}
+
+	return report
+}
+
+// requiredEmbeddedSCTs returns the number of embedded SCTs needed for the certificate's lifetime.
+func requiredEmbeddedSCTs(leaf *ctx509.Certificate) int {
+	if leaf.NotAfter.Sub(leaf.NotBefore) <= chromeShortLifetime {
+		return chromeShortLifetimeSCTs
+	}
+	return chromeLongLifetimeSCTs
+}
+
+// checkEmbeddedRequirement returns an error unless the leaf embeds valid SCTs from enough
+// distinct logs for its lifetime.
+func (c *checker) checkEmbeddedRequirement(chain []*ctx509.Certificate) error {
+	needed := requiredEmbeddedSCTs(chain[0])
+
+	logs := make(map[string]bool)
+	var lastErr error
+	for _, s := range c.verifyEmbeddedSCTs(nil, chain[0], c.issuerFor(context.Background(), chain)) {
+		if s.Valid() {
+			logs[s.LogID] = true
+		} else {
+			lastErr = s.Err
+		}
+	}
+
+	if len(logs) < needed {
+		err := fmt.Errorf("embedded SCT requirement not met: valid embedded SCTs from %d distinct log(s), need %d", len(logs), needed)
+		if lastErr != nil {
+			err = fmt.Errorf("%v (last error: %v)", err, lastErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Policy is a preset CT policy for CheckConnectionStatePolicy.
+type Policy int
+
+const (
+	// PolicyAtLeastOne requires one valid SCT from any source, as CheckConnectionState does.
+	PolicyAtLeastOne Policy = iota
+	// PolicyChrome requires compliance with Chrome's CT policy, see ComplianceReport.
+	PolicyChrome
+	// PolicyApple requires compliance with Apple's CT policy, see AppleComplianceReport.
+	PolicyApple
+	// PolicyStrictRFC requires compliance with Chrome's CT policy counting only the SCTs valid
+	// under Options.StrictRFC6962, whatever the checker's options. It is thus stronger than
+	// PolicyChrome.
+	PolicyStrictRFC
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyAtLeastOne:
+		return "at_least_one"
+	case PolicyChrome:
+		return "chrome"
+	case PolicyApple:
+		return "apple"
+	case PolicyStrictRFC:
+		return "strict_rfc"
+	default:
+		return "unknown"
+	}
+}
+
+// CheckConnectionStatePolicy checks the connection state against a preset policy using the
+// default checker. See (*checker).CheckConnectionStatePolicy.
+func CheckConnectionStatePolicy(state *tls.ConnectionState, policy Policy) (*Result, error) {
+	return GetDefaultChecker().CheckConnectionStatePolicy(state, policy)
+}
+
+// CheckConnectionStatePolicy verifies every SCT of the connection state, as
+// CheckConnectionStateDetailed does, and returns an error unless they meet the preset policy.
+// The result is returned along with a policy error, so callers can see which SCTs fell short;
+// it is nil only if the connection state could not be examined. If CT does not apply to the
+// certificate (Result.NotApplicable), the result is returned with ErrCTNotApplicable.
+func (c *checker) CheckConnectionStatePolicy(state *tls.ConnectionState, policy Policy) (*Result, error) {
+	switch policy {
+	case PolicyAtLeastOne:
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			return nil, err
+		}
+		if result.NotApplicable {
+			return result, ErrCTNotApplicable
+		}
+		if result.ValidCount() == 0 {
+			return result, fmt.Errorf("%v policy not met: no valid SCT", policy)
+		}
+		return result, nil
+	case PolicyChrome, PolicyApple, PolicyStrictRFC:
+		var report *Report
+		switch policy {
+		case PolicyChrome:
+			report = c.ComplianceReport(state)
+		case PolicyApple:
+			report = c.AppleComplianceReport(state)
+		default:
+			opts := c.opts
+			opts.StrictRFC6962 = true
+			report = c.withOptions(opts).ComplianceReport(state)
+		}
+		if report.Result == nil {
+			return nil, errors.New(report.Violations[0])
+		}
+		if report.Result.NotApplicable {
+			return report.Result, ErrCTNotApplicable
+		}
+		if !report.Compliant() {
+			return report.Result, fmt.Errorf("%v policy not met: %s", policy, strings.Join(report.Violations, "; "))
+		}
+		return report.Result, nil
+	default:
+		return nil, fmt.Errorf("unknown policy %d", policy)
+	}
+}
+
+// DefaultPolicyRanking ranks the preset policies for StrongestPolicy, strongest first. Each
+// policy implies the ones ranked below it.
+var DefaultPolicyRanking = []Policy{PolicyStrictRFC, PolicyChrome, PolicyAtLeastOne}
+
+// StrongestPolicy finds the strongest policy the connection state meets using the default
+// checker. See (*checker).StrongestPolicy.
+func StrongestPolicy(state *tls.ConnectionState, ranking []Policy) (Policy, *Result, error) {
+	return GetDefaultChecker().StrongestPolicy(state, ranking)
+}
+
+// StrongestPolicy checks the connection state against each policy of ranking in turn, strongest
+// first, as CheckConnectionStatePolicy does, and returns the first one met along with its result,
+// e.g. to classify a fleet's CT maturity in one pass. A nil ranking means DefaultPolicyRanking.
+// Each policy evaluated verifies the SCTs anew.
+//
+// If no policy is met, the error lists why each failed, and the policy returned is meaningless;
+// the result is that of the last policy, if the connection state could be examined at all. If CT
+// does not apply to the certificate, no policy is evaluated further and ErrCTNotApplicable is
+// returned with the result.
+func (c *checker) StrongestPolicy(state *tls.ConnectionState, ranking []Policy) (Policy, *Result, error) {
+	if ranking == nil {
+		ranking = DefaultPolicyRanking
+	}
+	if len(ranking) == 0 {
+		return 0, nil, errors.New("no policies to check")
+	}
+
+	var result *Result
+	var failures []string
+	for _, policy := range ranking {
+		var err error
+		result, err = c.CheckConnectionStatePolicy(state, policy)
+		if err == nil {
+			return policy, result, nil
+		}
+		if result == nil {
+			return 0, nil, err
+		}
+		if err == ErrCTNotApplicable {
+			return 0, result, err
+		}
+		failures = append(failures, err.Error())
+	}
+
+	return 0, result, fmt.Errorf("no policy met: %s", strings.Join(failures, "; "))
+}
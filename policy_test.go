@@ -0,0 +1,227 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+func TestComplianceReportListsEveryViolation(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}
+
+	report := newTestChecker(l).ComplianceReport(state)
+	if report.Compliant() {
+		t.Fatal("certificate with a single SCT reported compliant")
+	}
+
+	// A single unproven SCT from a single operator fails three ways at once.
+	violations := strings.Join(report.Violations, "\n")
+	for _, want := range []string{"inclusion of SCT", "too few valid SCTs", "operator diversity"} {
+		if !strings.Contains(violations, want) {
+			t.Errorf("violations %q do not mention %q", report.Violations, want)
+		}
+	}
+}
+
+func TestComplianceReportCounts(t *testing.T) {
+	logs := []*testLog{newTestLog(t, "Log A"), newTestLog(t, "Log B"), newTestLog(t, "Log C")}
+	retired := newTestLog(t, "Retired Log")
+	retired.log.State = &loglist2.LogStates{Retired: &loglist2.LogState{Timestamp: time.Now()}}
+	ca := newTestCA(t)
+
+	short := ca.issueWithEmbeddedSCTs(t, leafTemplate(), logs[0], logs[1], retired)
+	long := leafTemplate()
+	long.NotAfter = long.NotBefore.Add(365 * 24 * time.Hour)
+	longLeaf := ca.issueWithEmbeddedSCTs(t, long, logs[0], logs[1])
+
+	c := newMultiOperatorChecker(append(logs, retired)...)
+	c.opts.WarnOnInclusionFailure = true
+
+	report := c.ComplianceReport(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{short, ca.cert}})
+	// One for the retired log, and one unproven inclusion per valid SCT.
+	if len(report.Violations) != 4 || !strings.Contains(strings.Join(report.Violations, "\n"), "retired log") {
+		t.Errorf("short-lived certificate violations = %q, want retired log and 3 unproven inclusions", report.Violations)
+	}
+
+	report = c.ComplianceReport(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{longLeaf, ca.cert}})
+	if !strings.Contains(strings.Join(report.Violations, "\n"), "2 embedded (need 3)") {
+		t.Errorf("long-lived certificate violations = %q, want too few embedded SCTs", report.Violations)
+	}
+}
+
+func TestRequireEmbedded(t *testing.T) {
+	logs := []*testLog{newTestLog(t, "Log A"), newTestLog(t, "Log B"), newTestLog(t, "TLS Log")}
+	ca := newTestCA(t)
+	oneEmbedded := ca.issueWithEmbeddedSCTs(t, leafTemplate(), logs[0])
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, oneEmbedded, ca.cert))
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{oneEmbedded, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, logs[2].sign(t, merkleLeaf, time.Now()))},
+	}
+	c := newTestChecker(logs...)
+
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Fatalf("CheckConnectionState without RequireEmbedded: %v", err)
+	}
+
+	c.opts.RequireEmbedded = true
+	err := c.CheckConnectionState(state)
+	if err == nil || !strings.Contains(err.Error(), "embedded SCT requirement not met: valid embedded SCTs from 1 distinct log(s), need 2") {
+		t.Errorf("one embedded SCT plus a TLS SCT: got %v", err)
+	}
+
+	twoEmbedded := ca.issueWithEmbeddedSCTs(t, leafTemplate(), logs[0], logs[1])
+	state.PeerCertificates = []*x509.Certificate{twoEmbedded, ca.cert}
+	state.SignedCertificateTimestamps = nil
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Errorf("two embedded SCTs: %v", err)
+	}
+}
+
+func TestAppleComplianceReport(t *testing.T) {
+	logA, logB := newTestLog(t, "Log A"), newTestLog(t, "Log B")
+	retired := newTestLog(t, "Retired Log")
+	retired.log.State = &loglist2.LogStates{Retired: &loglist2.LogState{Timestamp: time.Now()}}
+	ca := newTestCA(t)
+	multi := newMultiOperatorChecker(logA, logB, retired)
+	state := func(leaf *x509.Certificate, tlsSCTs ...[]byte) *tls.ConnectionState {
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}, SignedCertificateTimestamps: tlsSCTs}
+	}
+	plain := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, plain, ca.cert))
+
+	tests := []struct {
+		name  string
+		c     *checker
+		state *tls.ConnectionState
+		want  []string
+	}{
+		{
+			// Unlike Chrome's policy, unproven inclusion is fine.
+			name:  "two embedded SCTs from two operators",
+			c:     multi,
+			state: state(ca.issueWithEmbeddedSCTs(t, leafTemplate(), logA, logB)),
+		},
+		{
+			name:  "embedded SCT from a log retired after issuing it",
+			c:     multi,
+			state: state(ca.issueWithEmbeddedSCTs(t, leafTemplate(), logA, retired)),
+		},
+		{
+			name:  "two embedded SCTs from one operator",
+			c:     newTestChecker(logA, logB),
+			state: state(ca.issueWithEmbeddedSCTs(t, leafTemplate(), logA, logB)),
+			want:  []string{"counted SCTs from 1 operator(s)"},
+		},
+		{
+			name:  "one log counts once",
+			c:     multi,
+			state: state(ca.issueWithEmbeddedSCTs(t, leafTemplate(), logA, logA)),
+			want:  []string{"1 embedded (need 2)", "operator diversity"},
+		},
+		{
+			name: "delivered SCT from a retired log",
+			c:    multi,
+			state: state(plain,
+				marshalSCT(t, logA.sign(t, merkleLeaf, time.Now())),
+				marshalSCT(t, retired.sign(t, merkleLeaf, time.Now().Add(-time.Hour)))),
+			want: []string{"1 delivered via TLS or OCSP (need 2)"},
+		},
+	}
+	for _, tt := range tests {
+		tt.c.opts.WarnOnInclusionFailure = true
+		report := tt.c.AppleComplianceReport(tt.state)
+		violations := strings.Join(report.Violations, "\n")
+		if len(tt.want) == 0 && !report.Compliant() {
+			t.Errorf("%s: violations %q, want compliant", tt.name, report.Violations)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(violations, want) {
+				t.Errorf("%s: violations %q do not mention %q", tt.name, report.Violations, want)
+			}
+		}
+	}
+}
+
+func TestCheckConnectionStatePolicy(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, leafTemplate(), l), ca.cert},
+	}
+	c := newTestChecker(l)
+
+	tests := []struct {
+		policy  Policy
+		wantErr bool
+	}{
+		{PolicyAtLeastOne, false},
+		// One log from one operator, and inclusion cannot be proven.
+		{PolicyChrome, true},
+		{PolicyApple, true},
+		{PolicyStrictRFC, true},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			result, err := c.CheckConnectionStatePolicy(state, test.policy)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("error = %v, want error: %v", err, test.wantErr)
+			}
+			if result == nil {
+				t.Error("no result returned")
+			}
+		})
+	}
+
+	if c.opts.StrictRFC6962 {
+		t.Error("PolicyStrictRFC changed the checker's options")
+	}
+	// PolicyStrictRFC implies PolicyChrome: it counts SCTs as Chrome's policy does.
+	if _, err := c.CheckConnectionStatePolicy(state, PolicyStrictRFC); err == nil || !strings.Contains(err.Error(), "too few valid SCTs") {
+		t.Errorf("PolicyStrictRFC error = %v, want it to report Chrome's SCT count requirement", err)
+	}
+	// Strict checks reuse the checker's log clients rather than building their own.
+	logInfo, err := c.logInfoForLog(l.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strict, err := c.withOptions(Options{StrictRFC6962: true}).logInfoForLog(l.log); err != nil || strict != logInfo {
+		t.Errorf("strict checker built its own client for the log (error %v)", err)
+	}
+	if _, err := c.CheckConnectionStatePolicy(state, Policy(-1)); err == nil {
+		t.Error("CheckConnectionStatePolicy accepted an unknown policy")
+	}
+}
+
+func TestStrongestPolicy(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, leafTemplate(), l), ca.cert},
+	}
+	c := newTestChecker(l)
+
+	// Inclusion cannot be proven and there is a single operator: only PolicyAtLeastOne is met.
+	policy, result, err := c.StrongestPolicy(state, nil)
+	if err != nil {
+		t.Fatalf("StrongestPolicy: %v", err)
+	}
+	if policy != PolicyAtLeastOne || result == nil || result.ValidCount() != 1 {
+		t.Errorf("StrongestPolicy = %v with result %v, want %v with 1 valid SCT", policy, result, PolicyAtLeastOne)
+	}
+
+	_, result, err = c.StrongestPolicy(state, []Policy{PolicyStrictRFC, PolicyChrome})
+	if err == nil || !strings.Contains(err.Error(), "strict_rfc") || !strings.Contains(err.Error(), "chrome") {
+		t.Errorf("StrongestPolicy without a policy met = %v, want an error naming both policies", err)
+	}
+	if result == nil {
+		t.Error("no result returned when no policy is met")
+	}
+}
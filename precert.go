@@ -0,0 +1,167 @@
+package sct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// altEncodingWarning is recorded on embedded SCTs only valid over the alternate precertificate
+// encoding, see embeddedMerkleLeaves.
+const altEncodingWarning = "SCT signed over the certificate's original TBSCertificate encoding rather than its DER re-encoding"
+
+// embeddedMerkleLeaves returns the Merkle leaves that embedded SCTs in leaf, issued by issuer, may
+// have been signed over. Each reconstructs the precertificate's TBSCertificate (RFC 6962 s3.2)
+// from the leaf's by removing the SCT list extension, but they encode it differently:
+//
+//  1. primary: the CT library's reconstruction, which parses the TBSCertificate and re-encodes it.
+//     It matches any precertificate in strict DER, and is what logs and CAs normally use.
+//  2. alt: the leaf's original TBSCertificate bytes with only the SCT list extension cut out,
+//     and the enclosing lengths adjusted. Everything else keeps its original encoding, which the
+//     re-encoding may change, e.g. by dropping an explicit critical FALSE from an extension or
+//     by re-encoding a time. CAs whose certificates contain such encodings sign SCTs over it.
+//
+// alt is nil if it cannot be built or has the same TBSCertificate as primary.
+func embeddedMerkleLeaves(leaf, issuer *ctx509.Certificate) (primary, alt *ct.MerkleTreeLeaf, err error) {
+	primary, err = ct.MerkleTreeLeafForEmbeddedSCT([]*ctx509.Certificate{leaf, issuer}, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tbs, spliceErr := spliceOutExtension(leaf.RawTBSCertificate, asn1.ObjectIdentifier(ctx509.OIDExtensionCTSCT))
+	if spliceErr != nil || bytes.Equal(tbs, primary.TimestampedEntry.PrecertEntry.TBSCertificate) {
+		return primary, nil, nil
+	}
+
+	alt = &ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.PrecertLogEntryType,
+			PrecertEntry: &ct.PreCert{
+				IssuerKeyHash:  sha256.Sum256(issuer.RawSubjectPublicKeyInfo),
+				TBSCertificate: tbs,
+			},
+		},
+	}
+	return primary, alt, nil
+}
+
+// TBSMismatchError is recorded against an embedded SCT whose signature verifies over neither
+// precertificate TBSCertificate encoding of embeddedMerkleLeaves, when the two encodings differ.
+// The leaf then contains non-DER encodings that the CA may have transformed in yet another way
+// when building the precertificate, so the failure likely lies in reconstructing the signed TBS
+// rather than in the signature itself.
+type TBSMismatchError struct {
+	// Reconstructed and Original are the SHA-256 hashes of the re-encoded and the original
+	// precertificate TBSCertificate.
+	Reconstructed, Original [sha256.Size]byte
+	// Err is the signature failure over the re-encoded TBSCertificate.
+	Err error
+}
+
+func (e *TBSMismatchError) Error() string {
+	return fmt.Sprintf("TBS reconstruction mismatch: SCT signature verifies over neither the re-encoded (sha256 %x) nor the original (sha256 %x) precertificate TBSCertificate: %v",
+		e.Reconstructed, e.Original, e.Err)
+}
+
+func (e *TBSMismatchError) Unwrap() error { return e.Err }
+
+// diagnoseEmbeddedSignature explains err, the signature failure of an SCT embedded in leaf over
+// primary, the re-encoded Merkle leaf of embeddedMerkleLeaves. If the re-encoded and original
+// TBSCertificates hash alike, the signed bytes are unambiguous and the signature is bad;
+// otherwise err becomes a TBSMismatchError. err is returned unchanged if the original
+// TBSCertificate cannot be rebuilt.
+func diagnoseEmbeddedSignature(err error, leaf *ctx509.Certificate, primary *ct.MerkleTreeLeaf) error {
+	tbs, spliceErr := spliceOutExtension(leaf.RawTBSCertificate, asn1.ObjectIdentifier(ctx509.OIDExtensionCTSCT))
+	if spliceErr != nil {
+		return err
+	}
+
+	reconstructed := sha256.Sum256(primary.TimestampedEntry.PrecertEntry.TBSCertificate)
+	original := sha256.Sum256(tbs)
+	if reconstructed == original {
+		return fmt.Errorf("%w (precertificate TBSCertificate is unambiguous, sha256 %x: the signature is bad)", err, original)
+	}
+	return &TBSMismatchError{Reconstructed: reconstructed, Original: original, Err: err}
+}
+
+// spliceOutExtension returns the DER TBSCertificate tbs without its extension with the given OID,
+// leaving the bytes of every other field and extension untouched. An extensions field left
+// empty is removed, as DER requires.
+func spliceOutExtension(tbs []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	input := cryptobyte.String(tbs)
+	var fields cryptobyte.String
+	if !input.ReadASN1(&fields, cbasn1.SEQUENCE) || !input.Empty() {
+		return nil, errors.New("malformed TBSCertificate")
+	}
+
+	extensionsTag := cbasn1.Tag(3).Constructed().ContextSpecific()
+	found := false
+	var b cryptobyte.Builder
+	var parseErr error
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !fields.Empty() {
+			var field cryptobyte.String
+			var tag cbasn1.Tag
+			if !fields.ReadAnyASN1Element(&field, &tag) {
+				parseErr = errors.New("malformed TBSCertificate field")
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(field)
+				continue
+			}
+
+			var explicit, extensions cryptobyte.String
+			if !field.ReadASN1(&explicit, extensionsTag) || !explicit.ReadASN1(&extensions, cbasn1.SEQUENCE) {
+				parseErr = errors.New("malformed TBSCertificate extensions")
+				return
+			}
+			var kept [][]byte
+			for !extensions.Empty() {
+				var ext, body cryptobyte.String
+				var extOID asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&ext, cbasn1.SEQUENCE) {
+					parseErr = errors.New("malformed extension")
+					return
+				}
+				element := ext
+				if !element.ReadASN1(&body, cbasn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&extOID) {
+					parseErr = errors.New("malformed extension")
+					return
+				}
+				if extOID.Equal(oid) {
+					found = true
+					continue
+				}
+				kept = append(kept, ext)
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, ext := range kept {
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if !found {
+		return nil, fmt.Errorf("no extension %v in TBSCertificate", oid)
+	}
+
+	return b.Bytes()
+}
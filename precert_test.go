@@ -0,0 +1,205 @@
+package sct
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// rawTBS is a TBSCertificate whose fields are kept as encoded, so that extensions can be appended
+// without re-encoding anything else.
+type rawTBS struct {
+	Version    int `asn1:"optional,explicit,default:0,tag:0"`
+	Serial     asn1.RawValue
+	SigAlg     asn1.RawValue
+	Issuer     asn1.RawValue
+	Validity   asn1.RawValue
+	Subject    asn1.RawValue
+	PublicKey  asn1.RawValue
+	Extensions []asn1.RawValue `asn1:"optional,explicit,tag:3"`
+}
+
+// appendExtension returns tbs with the DER extension ext appended.
+func appendExtension(t *testing.T, tbs []byte, ext []byte) []byte {
+	t.Helper()
+	var parsed rawTBS
+	if _, err := asn1.Unmarshal(tbs, &parsed); err != nil {
+		t.Fatalf("failed to parse TBSCertificate: %v", err)
+	}
+	parsed.Extensions = append(parsed.Extensions, asn1.RawValue{FullBytes: ext})
+	out, err := asn1.Marshal(parsed)
+	if err != nil {
+		t.Fatalf("failed to marshal TBSCertificate: %v", err)
+	}
+	return out
+}
+
+func TestSpliceOutExtensionMatchesDER(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	want, err := ctx509.RemoveSCTList(leaf.RawTBSCertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := spliceOutExtension(leaf.RawTBSCertificate, asn1.ObjectIdentifier(testOIDSCTList))
+	if err != nil {
+		t.Fatalf("spliceOutExtension: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("spliceOutExtension differs from the CT library on a DER certificate")
+	}
+
+	if _, err := spliceOutExtension(leaf.RawTBSCertificate, asn1.ObjectIdentifier{1, 2, 3}); err == nil {
+		t.Error("spliceOutExtension succeeded without the extension")
+	}
+}
+
+// explicitFalseLeaf returns a leaf issued by ca with an extension holding an explicit critical
+// FALSE, which re-encoding as DER drops, and an SCT from l signed over the precertificate
+// TBSCertificate returned by signedTBS, given the original one.
+func explicitFalseLeaf(t *testing.T, l *testLog, ca *testCA, signedTBS func(precertTBS []byte) []byte) *x509.Certificate {
+	t.Helper()
+	base := ca.issue(t, leafTemplate())
+
+	explicitFalse, err := asn1.Marshal(struct {
+		ID       asn1.ObjectIdentifier
+		Critical bool
+		Value    []byte
+	}{asn1.ObjectIdentifier{1, 2, 3, 4}, false, []byte{0x05, 0x00}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	precertTBS := appendExtension(t, base.RawTBSCertificate, explicitFalse)
+
+	sct := l.sign(t, &ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.PrecertLogEntryType,
+			PrecertEntry: &ct.PreCert{
+				IssuerKeyHash:  sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo),
+				TBSCertificate: signedTBS(precertTBS),
+			},
+		},
+	}, time.Now().Add(-time.Minute))
+	listData, err := cttls.Marshal(ctx509.SignedCertificateTimestampList{
+		SCTList: []ctx509.SerializedSCT{{Val: marshalSCT(t, sct)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extValue, err := asn1.Marshal(listData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctExt, err := asn1.Marshal(struct {
+		ID    asn1.ObjectIdentifier
+		Value []byte
+	}{asn1.ObjectIdentifier(testOIDSCTList), extValue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbs := appendExtension(t, precertTBS, sctExt)
+
+	var baseCert struct {
+		TBS    asn1.RawValue
+		SigAlg asn1.RawValue
+		Sig    asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(base.Raw, &baseCert); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	sig, err := ecdsa.SignASN1(rand.Reader, ca.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		TBS    asn1.RawValue
+		SigAlg asn1.RawValue
+		Sig    asn1.BitString
+	}{asn1.RawValue{FullBytes: tbs}, baseCert.SigAlg, asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestEmbeddedSCTAlternateEncoding(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := explicitFalseLeaf(t, l, ca, func(precertTBS []byte) []byte { return precertTBS })
+
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}
+	c := newTestChecker(l)
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 || len(result.SCTs[0].Warnings) != 1 {
+		t.Errorf("got %d valid SCTs with warnings %v; want 1 valid with the encoding warning", result.ValidCount(), result.SCTs[0].Warnings)
+	}
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Errorf("CheckConnectionState: %v", err)
+	}
+}
+
+func TestEmbeddedSignatureDiagnosis(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	c := newTestChecker(l)
+
+	check := func(leaf *x509.Certificate) error {
+		t.Helper()
+		result, err := c.CheckConnectionStateDetailed(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}})
+		if err != nil {
+			t.Fatalf("CheckConnectionStateDetailed: %v", err)
+		}
+		if len(result.SCTs) != 1 || result.SCTs[0].Valid() {
+			t.Fatalf("got %d SCT results, want 1 invalid", len(result.SCTs))
+		}
+		return result.SCTs[0].Err
+	}
+
+	// The CA signed a third encoding of the precertificate: neither reconstruction matches it.
+	leaf := explicitFalseLeaf(t, l, ca, func(precertTBS []byte) []byte {
+		return appendExtension(t, precertTBS, []byte{0x30, 0x06, 0x06, 0x01, 0x2a, 0x04, 0x01, 0x00})
+	})
+	var mismatch *TBSMismatchError
+	if err := check(leaf); !errors.As(err, &mismatch) {
+		t.Errorf("got error %v, want a TBSMismatchError", err)
+	} else if mismatch.Reconstructed == mismatch.Original {
+		t.Errorf("TBSMismatchError hashes are equal: %x", mismatch.Original)
+	}
+
+	// A DER leaf whose SCT covers another certificate: the signature is bad.
+	other := leafTemplate()
+	other.SerialNumber = big.NewInt(99)
+	leaf = ca.issue(t, leafTemplate(), l.sign(t, ca.precertLeaf(t, other), time.Now().Add(-time.Minute)))
+	err := check(leaf)
+	if errors.As(err, &mismatch) || !strings.Contains(err.Error(), "the signature is bad") {
+		t.Errorf("got error %v, want a bad signature over an unambiguous TBSCertificate", err)
+	}
+	if reason := metricReason(err); reason != MetricReasonBadSignature {
+		t.Errorf("metricReason = %q, want %q", reason, MetricReasonBadSignature)
+	}
+}
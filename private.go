@@ -0,0 +1,43 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// ErrCTNotApplicable is returned by CheckConnectionState, with Options.AllowNoSCTsForPrivateRoots,
+// for a certificate without any SCT which chains to one of Options.PrivateRoots. Such a
+// certificate was never meant to be logged, so it neither passes nor fails the check.
+var ErrCTNotApplicable = errors.New("certificate chains to a private root: CT not applicable")
+
+// ctNotApplicable returns true if Options.AllowNoSCTsForPrivateRoots applies to the connection:
+// no SCT was delivered by any means, and the peer certificates chain to a private root.
+func (c *checker) ctNotApplicable(state *tls.ConnectionState, leaf *ctx509.Certificate) bool {
+	if !c.opts.AllowNoSCTsForPrivateRoots || c.opts.PrivateRoots == nil {
+		return false
+	}
+
+	if len(state.SignedCertificateTimestamps) > 0 || len(leaf.SCTList.SCTList) > 0 {
+		return false
+	}
+	if len(state.OCSPResponse) > 0 {
+		if scts, err := parseOCSPSCTs(state.OCSPResponse, state.PeerCertificates[0]); err == nil && len(scts) > 0 {
+			return false
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         c.opts.PrivateRoots,
+		Intermediates: intermediates,
+		CurrentTime:   c.opts.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
@@ -0,0 +1,71 @@
+package sct
+
+import "sync"
+
+// Progress counts the checks completed so far by a batch, see Options.OnProgress.
+type Progress struct {
+	// Done is the number of checks completed: the sum of the other counts.
+	Done int
+	// Passed counts checks with at least one valid SCT, or to which CT does not apply.
+	Passed int
+	// Failed counts checks which ran but found no valid SCT.
+	Failed int
+	// Errors counts checks which could not run, e.g. because the host could not be reached.
+	Errors int
+}
+
+// progressTracker counts the checks of one batch and reports them to Options.OnProgress.
+type progressTracker struct {
+	mu       sync.Mutex
+	progress Progress
+	every    int
+	report   func(Progress)
+}
+
+// newProgressTracker returns a tracker for a batch, or nil if progress is not reported.
+func (c *checker) newProgressTracker() *progressTracker {
+	if c.opts.OnProgress == nil {
+		return nil
+	}
+	every := c.opts.ProgressInterval
+	if every < 1 {
+		every = 1
+	}
+	return &progressTracker{every: every, report: c.opts.OnProgress}
+}
+
+// record counts a completed check, whose result is nil if it could not run. It is safe for
+// concurrent use; reports are made one at a time.
+func (t *progressTracker) record(result *Result) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Done++
+	switch {
+	case result == nil:
+		t.progress.Errors++
+	case result.NotApplicable || result.ValidCount() > 0:
+		t.progress.Passed++
+	default:
+		t.progress.Failed++
+	}
+	if t.progress.Done%t.every == 0 {
+		t.report(t.progress)
+	}
+}
+
+// finish reports the final counts, unless they were just reported.
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.Done%t.every != 0 {
+		t.report(t.progress)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build go1.21
+// +build go1.21
+
+package sct
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+// TestCheckConnectionStateQUIC checks the connection state of a QUIC handshake, as seen by QUIC
+// implementations built on crypto/tls. SCTs arrive in the same fields as over TCP.
+func TestCheckConnectionStateQUIC(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := tls.QUICClient(&tls.QUICConfig{TLSConfig: &tls.Config{
+		ServerName: "example.com",
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS13,
+	}})
+	server := tls.QUICServer(&tls.QUICConfig{TLSConfig: &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		Certificates: []tls.Certificate{{
+			Certificate:                 [][]byte{leaf.Raw, ca.cert.Raw},
+			PrivateKey:                  ca.leafKey,
+			SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))},
+		}},
+	}})
+	defer client.Close()
+	defer server.Close()
+
+	runQUICHandshake(t, client, server)
+
+	state := client.ConnectionState()
+	if len(state.SignedCertificateTimestamps) != 1 {
+		t.Fatalf("got %d SCTs from the QUIC handshake, want 1", len(state.SignedCertificateTimestamps))
+	}
+	if err := newTestChecker(l).CheckConnectionState(&state); err != nil {
+		t.Errorf("CheckConnectionState on a QUIC connection: %v", err)
+	}
+}
+
+// runQUICHandshake completes a handshake between client and server over in-memory CRYPTO frames.
+func runQUICHandshake(t *testing.T, client, server *tls.QUICConn) {
+	t.Helper()
+	ctx := context.Background()
+	client.SetTransportParameters(nil)
+	if err := client.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	conns := map[*tls.QUICConn]*tls.QUICConn{client: server, server: client}
+	done := map[*tls.QUICConn]bool{}
+	for !done[client] || !done[server] {
+		progressed := false
+		for conn, peer := range conns {
+			for {
+				e := conn.NextEvent()
+				if e.Kind == tls.QUICNoEvent {
+					break
+				}
+				progressed = true
+				switch e.Kind {
+				case tls.QUICWriteData:
+					if err := peer.HandleData(e.Level, e.Data); err != nil {
+						t.Fatalf("QUIC handshake: %v", err)
+					}
+				case tls.QUICTransportParametersRequired:
+					conn.SetTransportParameters(nil)
+				case tls.QUICHandshakeDone:
+					done[conn] = true
+				}
+			}
+		}
+		if !progressed {
+			t.Fatal("QUIC handshake stalled")
+		}
+	}
+}
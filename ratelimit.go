@@ -0,0 +1,102 @@
+package sct
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit bounds the rate of requests to a log. The zero value means no limit.
+type RateLimit struct {
+	// PerSecond is the sustained number of requests per second.
+	PerSecond float64
+	// Burst is the number of requests that may be made at once after a quiet period.
+	// Values below 1 are treated as 1.
+	Burst int
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a limiter enforcing l, or nil if l sets no limit.
+func newTokenBucket(l RateLimit) *tokenBucket {
+	if l.PerSecond <= 0 {
+		return nil
+	}
+
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   l.PerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a request may be made, or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimitedTransport waits for its limiter before passing each request on.
+type rateLimitedTransport struct {
+	limiter *tokenBucket
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// logHTTPClient returns the HTTP client for requests to the log with the given description,
+// throttled by limiter if non-nil, and reporting every exchange to record if non-nil.
+func logHTTPClient(log string, limiter *tokenBucket, record func(*LogExchange)) *http.Client {
+	if limiter == nil && record == nil {
+		return http.DefaultClient
+	}
+
+	transport := http.DefaultTransport
+	if record != nil {
+		transport = &recordingTransport{log: log, record: record, base: transport}
+	}
+	if limiter != nil {
+		transport = &rateLimitedTransport{limiter: limiter, base: transport}
+	}
+	return &http.Client{Transport: transport}
+}
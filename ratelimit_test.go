@@ -0,0 +1,76 @@
+package sct
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	if newTokenBucket(RateLimit{}) != nil {
+		t.Error("zero RateLimit yielded a limiter")
+	}
+
+	b := newTokenBucket(RateLimit{PerSecond: 20, Burst: 2})
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two requests from the burst, then two more at 20 per second.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests took %v, want at least 100ms", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.wait(cancelled); err != context.Canceled {
+		t.Errorf("wait with cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLogRateLimits(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	limited := newTestLog(t, "Limited Log")
+	limited.log.URL = srv.URL
+	unlimited := newTestLog(t, "Unlimited Log")
+	c := newTestChecker(limited, unlimited)
+	c.opts.LogRateLimits = map[string]RateLimit{hex.EncodeToString(limited.log.LogID): {PerSecond: 20}}
+
+	if c.limiterFor(unlimited.log) != nil {
+		t.Error("log without a rate limit got a limiter")
+	}
+
+	logInfo, err := c.logInfoForLog(limited.log)
+	if err != nil {
+		t.Fatalf("logInfoForLog: %v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		logInfo.Client.GetSTH(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests to a log limited to 20 per second took %v, want at least 100ms", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("log received %d requests, want 3", n)
+	}
+
+	limiter := c.limiterFor(limited.log)
+	c.SetLogList(c.logList())
+	if c.limiterFor(limited.log) != limiter {
+		t.Error("rate limiter was reset by a log list change")
+	}
+}
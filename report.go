@@ -0,0 +1,83 @@
+package sct
+
+import (
+	"crypto/tls"
+	"strconv"
+	"time"
+)
+
+// CertReport is the outcome of a detailed check together with details of the leaf certificate,
+// flat enough to be written as one CSV record.
+type CertReport struct {
+	// Host is the host the connection state came from, for display. It may be empty.
+	Host string
+	// Result holds the per-SCT outcomes.
+	Result *Result
+	// ValidationLevel is the leaf's validation level, see ValidationLevel.
+	ValidationLevel string
+	// NotAfter is the leaf's expiry.
+	NotAfter time.Time
+}
+
+// CheckCertReport checks the connection state using the default checker. See (*checker).CheckCertReport.
+func CheckCertReport(host string, state *tls.ConnectionState) (*CertReport, error) {
+	return GetDefaultChecker().CheckCertReport(host, state)
+}
+
+// CheckCertReport verifies every SCT of the connection state, as CheckConnectionStateDetailed
+// does, and reports the outcome along with the leaf's validation level and expiry. host is
+// only recorded in the report.
+func (c *checker) CheckCertReport(host string, state *tls.ConnectionState) (*CertReport, error) {
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := ConvertCert(state.PeerCertificates[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertReport{
+		Host:            host,
+		Result:          result,
+		ValidationLevel: ValidationLevel(leaf),
+		NotAfter:        leaf.NotAfter,
+	}, nil
+}
+
+// CSVHeader returns the column names of the records returned by (*CertReport).CSVRecord.
+func CSVHeader() []string {
+	return []string{"host", "ct_status", "valid_scts", "operators", "validation_level", "not_after"}
+}
+
+// CSVRecord returns the report as a record for encoding/csv, with the columns named by
+// CSVHeader. The CT status is "pass" if at least one SCT is valid, as for CheckConnectionState,
+// "not_applicable" if the result is (see Options.AllowNoSCTsForPrivateRoots), and "fail"
+// otherwise; operators counts the distinct operators of the valid SCTs; the expiry is in
+// RFC 3339 format, in UTC.
+func (r *CertReport) CSVRecord() []string {
+	status := "fail"
+	switch {
+	case r.Result.NotApplicable:
+		status = "not_applicable"
+	case r.Result.ValidCount() > 0:
+		status = "pass"
+	}
+
+	operators := make(map[string]bool)
+	for _, s := range r.Result.SCTs {
+		if s.Valid() {
+			operators[s.Operator] = true
+		}
+	}
+
+	return []string{
+		r.Host,
+		status,
+		strconv.Itoa(r.Result.ValidCount()),
+		strconv.Itoa(len(operators)),
+		r.ValidationLevel,
+		r.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
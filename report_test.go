@@ -0,0 +1,32 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCertReportCSVRecord(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.NotAfter = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	leaf := ca.issueWithEmbeddedSCTs(t, tmpl, l)
+
+	report, err := newTestChecker(l).CheckCertReport("example.com:443", &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckCertReport: %v", err)
+	}
+
+	want := []string{"example.com:443", "pass", "1", "1", UnknownValidationLevel.String(), "2030-01-02T03:04:05Z"}
+	if got := report.CSVRecord(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVRecord() = %q, want %q", got, want)
+	}
+	if len(CSVHeader()) != len(want) {
+		t.Errorf("CSVHeader() has %d columns, want %d", len(CSVHeader()), len(want))
+	}
+}
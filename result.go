@@ -0,0 +1,482 @@
+package sct
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist2"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// SCTSource identifies how an SCT was delivered.
+type SCTSource int
+
+const (
+	// SourceTLSExtension is an SCT from the signed_certificate_timestamp TLS extension.
+	SourceTLSExtension SCTSource = iota
+	// SourceEmbedded is an SCT embedded in the leaf certificate.
+	SourceEmbedded
+	// SourceOCSP is an SCT from a stapled OCSP response.
+	SourceOCSP
+)
+
+func (s SCTSource) String() string {
+	switch s {
+	case SourceTLSExtension:
+		return "tls_extension"
+	case SourceEmbedded:
+		return "embedded"
+	case SourceOCSP:
+		return "ocsp"
+	default:
+		return "unknown"
+	}
+}
+
+// TLSExtensionStatus describes the signed_certificate_timestamp TLS extension of a connection.
+//
+// crypto/tls cannot tell these apart in every case: it aborts the handshake if a server sends
+// the extension with an empty list, and leaves tls.ConnectionState.SignedCertificateTimestamps
+// nil when the extension is absent. TLSExtensionEmpty is therefore only reported for connection
+// states built by other means (e.g. reconstructed from a captured handshake) which set the field
+// to a non-nil empty slice.
+type TLSExtensionStatus int
+
+const (
+	// TLSExtensionAbsent means the server did not send the extension.
+	TLSExtensionAbsent TLSExtensionStatus = iota
+	// TLSExtensionEmpty means the server sent the extension with no SCTs in it.
+	TLSExtensionEmpty
+	// TLSExtensionPresent means the server sent the extension with at least one SCT.
+	TLSExtensionPresent
+)
+
+func (s TLSExtensionStatus) String() string {
+	switch s {
+	case TLSExtensionAbsent:
+		return "absent"
+	case TLSExtensionEmpty:
+		return "empty"
+	case TLSExtensionPresent:
+		return "present"
+	default:
+		return "unknown"
+	}
+}
+
+func tlsExtensionStatus(scts [][]byte) TLSExtensionStatus {
+	switch {
+	case scts == nil:
+		return TLSExtensionAbsent
+	case len(scts) == 0:
+		return TLSExtensionEmpty
+	default:
+		return TLSExtensionPresent
+	}
+}
+
+// CertValidity describes a certificate's validity period relative to the checker's clock.
+type CertValidity int
+
+const (
+	// ValidityUnknown means the certificate's validity was not examined.
+	ValidityUnknown CertValidity = iota
+	// ValidityCurrent means the certificate is within its validity period.
+	ValidityCurrent
+	// ValidityNotYetValid means the certificate's NotBefore is in the future.
+	ValidityNotYetValid
+	// ValidityExpired means the certificate's NotAfter is in the past.
+	ValidityExpired
+)
+
+func (v CertValidity) String() string {
+	switch v {
+	case ValidityCurrent:
+		return "current"
+	case ValidityNotYetValid:
+		return "not_yet_valid"
+	case ValidityExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+func certValidity(cert *ctx509.Certificate, now time.Time) CertValidity {
+	switch {
+	case now.Before(cert.NotBefore):
+		return ValidityNotYetValid
+	case now.After(cert.NotAfter):
+		return ValidityExpired
+	default:
+		return ValidityCurrent
+	}
+}
+
+// SCTResult is the outcome of verifying a single SCT.
+type SCTResult struct {
+	Source SCTSource
+	// Raw is the serialized SCT exactly as received, for storing and later re-verification.
+	// Re-encoding the decoded SCT is not guaranteed to reproduce it. It is nil for SCTs that
+	// were passed in decoded, and shares memory with the input, so it must not be modified.
+	Raw []byte
+	// LogID is the hex-encoded KeyID of the log that issued the SCT. Unlike the
+	// log description, it is stable, making it suitable for joins with external datasets.
+	LogID string
+	// LogDescription is the description of the issuing log, if it is in the log list.
+	LogDescription string
+	// Operator is the name of the issuing log's operator, if the log is in the log list.
+	Operator string
+	// LogStatus is the issuing log's current state, if the log is in the log list.
+	LogStatus loglist2.LogStatus
+	// Discovered describes the issuing log if it is not in the log list but is known to the
+	// service at Options.LogDiscoveryURL.
+	Discovered *DiscoveredLog
+	Timestamp  time.Time
+	// IssuanceDelay is how long after the certificate's NotBefore the SCT was issued, see
+	// IssuanceDelay. It is negative for SCTs issued before NotBefore, as precertificate SCTs
+	// often are, and zero for SCTs which could not be decoded.
+	IssuanceDelay time.Duration
+	// InclusionVerified is true if the SCT's inclusion in the log was proven.
+	InclusionVerified bool
+	// InclusionSTH is the signed tree head inclusion was proven against, for re-verifying the
+	// proof later. It is only set with Options.RecordInclusionSTH.
+	InclusionSTH *ct.SignedTreeHead
+	// Warnings lists issues that did not cause the SCT to be rejected.
+	Warnings []string
+	// Err is nil if the SCT is valid.
+	Err error
+}
+
+// IssuanceDelay returns how long after cert's NotBefore an SCT timestamped at sctTime was issued,
+// a measure of how promptly the certificate was logged. CAs log precertificates before issuing
+// the final certificate, and often backdate NotBefore, so the delay is frequently negative.
+func IssuanceDelay(cert *ctx509.Certificate, sctTime time.Time) time.Duration {
+	return sctTime.Sub(cert.NotBefore)
+}
+
+// setIssuanceDelays sets the IssuanceDelay of each decoded SCT in results, issued for leaf.
+func setIssuanceDelays(results []*SCTResult, leaf *ctx509.Certificate) {
+	for _, s := range results {
+		if !s.Timestamp.IsZero() {
+			s.IssuanceDelay = IssuanceDelay(leaf, s.Timestamp)
+		}
+	}
+}
+
+// Valid returns true if the SCT passed verification.
+func (r *SCTResult) Valid() bool {
+	return r.Err == nil
+}
+
+// Result is the outcome of verifying every SCT presented for a certificate.
+type Result struct {
+	SCTs []*SCTResult
+	// TLSExtension reports whether the server sent the SCT TLS extension, and whether it was empty.
+	TLSExtension TLSExtensionStatus
+	// Validity reports whether the leaf certificate is currently within its validity period.
+	// Valid SCTs do not make an expired certificate acceptable.
+	Validity CertValidity
+	// ShortLived is true if the leaf certificate is short-lived, see IsShortLived.
+	ShortLived bool
+	// NotApplicable is true if the certificate has no SCTs and chains to a private root, see
+	// Options.AllowNoSCTsForPrivateRoots. It neither passes nor fails.
+	NotApplicable bool
+	// Warnings lists issues with the certificate that are not specific to one SCT.
+	Warnings []string
+	// Timings holds the time spent in each verification phase. It is only set with
+	// Options.RecordPhaseTimings.
+	Timings *PhaseTimings
+}
+
+// PhaseTimings is the time a detailed check spent in each phase of verification, summed over
+// its SCTs. Comparing them across a scan shows whether it is bound by the network (inclusion)
+// or the CPU (signatures).
+type PhaseTimings struct {
+	// ChainBuild is the time spent parsing the peer certificates into a chain.
+	ChainBuild time.Duration
+	// SCTParse is the time spent decoding serialized SCTs.
+	SCTParse time.Duration
+	// SignatureVerify is the time spent checking SCT signatures.
+	SignatureVerify time.Duration
+	// InclusionFetch is the time spent fetching and checking inclusion proofs.
+	InclusionFetch time.Duration
+}
+
+// phase identifies a field of PhaseTimings.
+type phase int
+
+const (
+	phaseChainBuild phase = iota
+	phaseSCTParse
+	phaseSignatureVerify
+	phaseInclusionFetch
+)
+
+func (t *PhaseTimings) add(ph phase, d time.Duration) {
+	switch ph {
+	case phaseChainBuild:
+		t.ChainBuild += d
+	case phaseSCTParse:
+		t.SCTParse += d
+	case phaseSignatureVerify:
+		t.SignatureVerify += d
+	case phaseInclusionFetch:
+		t.InclusionFetch += d
+	}
+}
+
+// ValidCount returns the number of SCTs that passed verification.
+func (r *Result) ValidCount() int {
+	n := 0
+	for _, s := range r.SCTs {
+		if s.Valid() {
+			n++
+		}
+	}
+	return n
+}
+
+// HasWarnings returns true if the result or any SCT carries warnings.
+func (r *Result) HasWarnings() bool {
+	if len(r.Warnings) > 0 {
+		return true
+	}
+	for _, s := range r.SCTs {
+		if len(s.Warnings) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary returns a one-line, human-readable outcome, such as
+// "PASS: 3 valid SCTs from 2 operators (Google, Cloudflare)" or
+// "FAIL: 0 valid SCTs (unknown log, bad signature)". The result passes if at least one SCT is
+// valid, as for CheckConnectionState. Operators and failure reasons are listed in order of
+// first appearance; the operators are left out if none of the valid SCTs' logs has one. A result
+// which is not applicable is summarized as "N/A".
+func (r *Result) Summary() string {
+	var operators, reasons []string
+	seenOperators := make(map[string]bool)
+	seenReasons := make(map[string]bool)
+	for _, s := range r.SCTs {
+		if s.Valid() {
+			if s.Operator != "" && !seenOperators[s.Operator] {
+				seenOperators[s.Operator] = true
+				operators = append(operators, s.Operator)
+			}
+			continue
+		}
+		if reason := failureReason(s.Err); !seenReasons[reason] {
+			seenReasons[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if r.NotApplicable {
+		return "N/A: no SCTs, private root"
+	}
+
+	n := r.ValidCount()
+	if n == 0 {
+		if len(r.SCTs) == 0 {
+			reasons = []string{"no SCTs"}
+		}
+		return fmt.Sprintf("FAIL: 0 valid SCTs (%s)", strings.Join(reasons, ", "))
+	}
+
+	summary := fmt.Sprintf("PASS: %d valid %s", n, plural(n, "SCT"))
+	if len(operators) > 0 {
+		summary += fmt.Sprintf(" from %d %s (%s)", len(operators), plural(len(operators), "operator"), strings.Join(operators, ", "))
+	}
+	if len(reasons) > 0 {
+		summary += fmt.Sprintf("; %d invalid (%s)", len(r.SCTs)-n, strings.Join(reasons, ", "))
+	}
+	return summary
+}
+
+// failureReason returns a short description of why an SCT failed verification with err.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, errUnknownLog):
+		return "unknown log"
+	case isSignatureError(err):
+		return "bad signature"
+	case errors.Is(err, errInclusionUnproven), errors.Is(err, errEntryNotFound):
+		return "inclusion unproven"
+	default:
+		return err.Error()
+	}
+}
+
+// Metric label values for the reasons of MetricLabels. Failures not covered by one of them are
+// counted as MetricReasonOther, which keeps label cardinality bounded.
+const (
+	MetricReasonNoSCTs            = "no_scts"
+	MetricReasonUnknownLog        = "unknown_log"
+	MetricReasonBadSignature      = "bad_signature"
+	MetricReasonInclusionUnproven = "inclusion_unproven"
+	MetricReasonLogMisbehavior    = "log_misbehavior"
+	MetricReasonMissingIssuer     = "missing_issuer"
+	MetricReasonFutureTimestamp   = "future_timestamp"
+	MetricReasonOther             = "other"
+)
+
+// MetricLabels is a result reduced to values fit for metric labels, e.g. for a monitoring
+// exporter, see (*Result).MetricLabels.
+type MetricLabels struct {
+	// Compliant is true if at least one SCT is valid, as for CheckConnectionState, or if the
+	// result is not applicable (see Options.AllowNoSCTsForPrivateRoots).
+	Compliant bool
+	// Reasons lists the MetricReason values of the invalid SCTs, sorted and without duplicates.
+	Reasons []string
+}
+
+// MetricLabels returns the result's outcome as metric labels. Unlike Summary, failure reasons
+// are drawn from a fixed set, so they can be used as labels without growing cardinality.
+func (r *Result) MetricLabels() MetricLabels {
+	labels := MetricLabels{Compliant: r.NotApplicable || r.ValidCount() > 0}
+	if r.NotApplicable {
+		return labels
+	}
+	if len(r.SCTs) == 0 {
+		labels.Reasons = []string{MetricReasonNoSCTs}
+		return labels
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range r.SCTs {
+		if reason := metricReason(s.Err); !s.Valid() && !seen[reason] {
+			seen[reason] = true
+			labels.Reasons = append(labels.Reasons, reason)
+		}
+	}
+	sort.Strings(labels.Reasons)
+	return labels
+}
+
+// metricReason returns the MetricReason value for an SCT that failed verification with err.
+func metricReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	var consistencyErr *LogConsistencyError
+	if errors.As(err, &consistencyErr) {
+		return MetricReasonLogMisbehavior
+	}
+
+	switch {
+	case errors.Is(err, errUnknownLog):
+		return MetricReasonUnknownLog
+	case errors.Is(err, errNoIssuer):
+		return MetricReasonMissingIssuer
+	case errors.Is(err, errFutureTimestamp):
+		return MetricReasonFutureTimestamp
+	case isSignatureError(err):
+		return MetricReasonBadSignature
+	case errors.Is(err, errInclusionUnproven), errors.Is(err, errEntryNotFound):
+		return MetricReasonInclusionUnproven
+	default:
+		return MetricReasonOther
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+// SCTChange is an SCT whose outcome differs between two results, see (*Result).Diff.
+type SCTChange struct {
+	Source SCTSource
+	Raw    []byte
+	// Before and After are the SCT's results in the receiver and the other result. One of them
+	// is nil if the SCT is only present in the other.
+	Before, After *SCTResult
+}
+
+// Equal returns true if r and other have the same outcome: the same certificate-level fields
+// and warnings, and no SCT changes (see Diff). It is meant for comparing runs over the same
+// input, e.g. before and after a log list update.
+func (r *Result) Equal(other *Result) bool {
+	return r.TLSExtension == other.TLSExtension &&
+		r.Validity == other.Validity &&
+		r.ShortLived == other.ShortLived &&
+		r.NotApplicable == other.NotApplicable &&
+		equalStrings(r.Warnings, other.Warnings) &&
+		len(r.Diff(other)) == 0
+}
+
+// Diff returns the SCTs whose outcome differs from r to other, in the order of r's SCTs followed
+// by those only in other. SCTs are matched by source and raw bytes; SCTs repeated with the same
+// source and bytes, including SCTs without raw bytes, are matched in order of appearance. An
+// outcome is the SCT's validity, its error message and its warnings; details such as the log
+// description are not compared.
+func (r *Result) Diff(other *Result) []*SCTChange {
+	type sctKey struct {
+		source SCTSource
+		raw    string
+		// n counts the previous SCTs with the same source and bytes.
+		n int
+	}
+	keys := func(scts []*SCTResult) []sctKey {
+		counts := make(map[sctKey]int, len(scts))
+		keys := make([]sctKey, len(scts))
+		for i, s := range scts {
+			first := sctKey{source: s.Source, raw: string(s.Raw)}
+			keys[i] = sctKey{source: s.Source, raw: string(s.Raw), n: counts[first]}
+			counts[first]++
+		}
+		return keys
+	}
+
+	beforeKeys, afterKeys := keys(r.SCTs), keys(other.SCTs)
+	after := make(map[sctKey]*SCTResult, len(other.SCTs))
+	for i, s := range other.SCTs {
+		after[afterKeys[i]] = s
+	}
+
+	var changes []*SCTChange
+	seen := make(map[sctKey]bool, len(r.SCTs))
+	for i, s := range r.SCTs {
+		key := beforeKeys[i]
+		seen[key] = true
+		if a := after[key]; a == nil || !sameOutcome(s, a) {
+			changes = append(changes, &SCTChange{Source: s.Source, Raw: s.Raw, Before: s, After: a})
+		}
+	}
+	for i, s := range other.SCTs {
+		if !seen[afterKeys[i]] {
+			changes = append(changes, &SCTChange{Source: s.Source, Raw: s.Raw, After: s})
+		}
+	}
+
+	return changes
+}
+
+// sameOutcome returns true if a and b have the same validity, error message and warnings.
+func sameOutcome(a, b *SCTResult) bool {
+	if a.Valid() != b.Valid() || !equalStrings(a.Warnings, b.Warnings) {
+		return false
+	}
+	return a.Valid() || a.Err.Error() == b.Err.Error()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package sct
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultDiff(t *testing.T) {
+	valid := &SCTResult{Source: SourceEmbedded, Raw: []byte{1}}
+	invalid := &SCTResult{Source: SourceEmbedded, Raw: []byte{1}, Err: errors.New("bad signature")}
+	tlsSCT := &SCTResult{Source: SourceTLSExtension, Raw: []byte{2}}
+	// Same bytes, other source: a different SCT.
+	ocspSCT := &SCTResult{Source: SourceOCSP, Raw: []byte{2}}
+
+	before := &Result{SCTs: []*SCTResult{valid, tlsSCT}}
+
+	if !before.Equal(&Result{SCTs: []*SCTResult{tlsSCT, {Source: SourceEmbedded, Raw: []byte{1}}}}) {
+		t.Error("Equal is false for the same SCT outcomes in another order")
+	}
+	if before.Equal(&Result{SCTs: before.SCTs, ShortLived: true}) {
+		t.Error("Equal is true for results with different certificate-level fields")
+	}
+
+	changes := before.Diff(&Result{SCTs: []*SCTResult{invalid, ocspSCT}})
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3", len(changes))
+	}
+	if c := changes[0]; c.Before != valid || c.After != invalid {
+		t.Errorf("change 0 = %+v, want embedded SCT turning invalid", c)
+	}
+	if c := changes[1]; c.Before != tlsSCT || c.After != nil {
+		t.Errorf("change 1 = %+v, want TLS SCT removed", c)
+	}
+	if c := changes[2]; c.Before != nil || c.After != ocspSCT {
+		t.Errorf("change 2 = %+v, want OCSP SCT added", c)
+	}
+
+	// Repeated SCTs, and SCTs without raw bytes, are matched in order rather than collapsed.
+	unparsed := &SCTResult{Source: SourceTLSExtension, Err: errors.New("failed to parse SCT")}
+	changes = (&Result{SCTs: []*SCTResult{valid, valid, unparsed}}).Diff(&Result{SCTs: []*SCTResult{valid, unparsed, unparsed}})
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	if c := changes[0]; c.Before != valid || c.After != nil {
+		t.Errorf("change 0 = %+v, want repeated embedded SCT removed", c)
+	}
+	if c := changes[1]; c.Before != nil || c.After != unparsed {
+		t.Errorf("change 1 = %+v, want unparsed TLS SCT added", c)
+	}
+}
+
+func TestResultSummary(t *testing.T) {
+	google := &SCTResult{Operator: "Google"}
+	cloudflare := &SCTResult{Operator: "Cloudflare"}
+	unknown := &SCTResult{Err: fmt.Errorf("%w with KeyID 00", errUnknownLog)}
+	badSig := &SCTResult{Operator: "Google", Err: signatureError{errors.New("failed to verify ECDSA signature")}}
+
+	tests := []struct {
+		name   string
+		result *Result
+		want   string
+	}{
+		{"pass", &Result{SCTs: []*SCTResult{google, cloudflare, google}}, "PASS: 3 valid SCTs from 2 operators (Google, Cloudflare)"},
+		{"pass with failures", &Result{SCTs: []*SCTResult{google, unknown}}, "PASS: 1 valid SCT from 1 operator (Google); 1 invalid (unknown log)"},
+		{"fail", &Result{SCTs: []*SCTResult{unknown, badSig, unknown}}, "FAIL: 0 valid SCTs (unknown log, bad signature)"},
+		{"no SCTs", &Result{}, "FAIL: 0 valid SCTs (no SCTs)"},
+		{"no operators", &Result{SCTs: []*SCTResult{{}}}, "PASS: 1 valid SCT"},
+		{"operator named like a reason", &Result{SCTs: []*SCTResult{{Operator: "unknown log"}, unknown}}, "PASS: 1 valid SCT from 1 operator (unknown log); 1 invalid (unknown log)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.result.Summary(); got != test.want {
+				t.Errorf("Summary() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResultMetricLabels(t *testing.T) {
+	valid := &SCTResult{Operator: "Google"}
+	unknown := &SCTResult{Err: fmt.Errorf("%w with KeyID 00", errUnknownLog)}
+	badSig := &SCTResult{Err: signatureError{errors.New("failed to verify ECDSA signature")}}
+	misbehaved := &SCTResult{Err: &LogConsistencyError{Log: "x", Err: errors.New("tree shrank")}}
+	odd := &SCTResult{Err: errors.New("something unexpected at 12:34:56")}
+
+	tests := []struct {
+		name          string
+		result        *Result
+		wantCompliant bool
+		wantReasons   string
+	}{
+		{"pass", &Result{SCTs: []*SCTResult{valid}}, true, ""},
+		{"pass with failures", &Result{SCTs: []*SCTResult{valid, badSig}}, true, "bad_signature"},
+		{"fail", &Result{SCTs: []*SCTResult{unknown, odd, misbehaved, badSig, unknown}}, false, "bad_signature,log_misbehavior,other,unknown_log"},
+		{"no SCTs", &Result{}, false, "no_scts"},
+		{"not applicable", &Result{NotApplicable: true}, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels := test.result.MetricLabels()
+			if labels.Compliant != test.wantCompliant || strings.Join(labels.Reasons, ",") != test.wantReasons {
+				t.Errorf("MetricLabels() = %+v, want compliant %v with reasons %q", labels, test.wantCompliant, test.wantReasons)
+			}
+		})
+	}
+}
+
+func TestFailureReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+		wantMetric string
+	}{
+		{"unknown log", fmt.Errorf("%w with KeyID 00 (0 logs loaded); unknown to the log discovery service", errUnknownLog), "unknown log", MetricReasonUnknownLog},
+		{"bad signature", signatureError{errors.New("failed to verify ECDSA signature")}, "bad signature", MetricReasonBadSignature},
+		{"TBS mismatch", &TBSMismatchError{Err: signatureError{errors.New("failed to verify ECDSA signature")}}, "bad signature", MetricReasonBadSignature},
+		{"inclusion", fmt.Errorf(`%w in log "x": no proof`, errInclusionUnproven), "inclusion unproven", MetricReasonInclusionUnproven},
+		{"no entry", fmt.Errorf(`log "x" has no entry for the SCT (SCT age 1h0m0s): %w`, errEntryNotFound), "inclusion unproven", MetricReasonInclusionUnproven},
+		{"future", fmt.Errorf(`SCT from log x is %w by 1h0m0s`, errFutureTimestamp), "", MetricReasonFutureTimestamp},
+		{"missing issuer", errNoIssuer, "", MetricReasonMissingIssuer},
+		// Errors mentioning signatures or inclusion, but which are not bad signatures or unproven inclusion.
+		{"RSA-PSS", errors.New("log x: RSA-PSS SCT signatures are not supported"), "", MetricReasonOther},
+		{"algorithm mismatch", errors.New("log x: SCT signature algorithm does not match log key: SCT uses RSA, log key is ECDSA"), "", MetricReasonOther},
+		{"issuer signature", errors.New(`leaf certificate is not signed by issuer "CA": x509: ECDSA verification failure`), "", MetricReasonOther},
+		{"inclusion wording", errors.New("no inclusion proof and STH to verify against"), "", MetricReasonOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wantReason := test.wantReason
+			if wantReason == "" {
+				wantReason = test.err.Error()
+			}
+			if got := failureReason(test.err); got != wantReason {
+				t.Errorf("failureReason = %q, want %q", got, wantReason)
+			}
+			if got := metricReason(test.err); got != test.wantMetric {
+				t.Errorf("metricReason = %q, want %q", got, test.wantMetric)
+			}
+		})
+	}
+}
+
+func TestIssuanceDelay(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.NotBefore = time.Now().Add(-time.Hour).Truncate(time.Second)
+	leaf := ca.issue(t, tmpl, l.sign(t, ca.precertLeaf(t, tmpl), tmpl.NotBefore.Add(-10*time.Minute)))
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	result, err := newTestChecker(l).CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, merkleLeaf, tmpl.NotBefore.Add(5*time.Minute)))},
+	})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	want := map[SCTSource]time.Duration{SourceTLSExtension: 5 * time.Minute, SourceEmbedded: -10 * time.Minute}
+	if len(result.SCTs) != len(want) {
+		t.Fatalf("got %d SCT results, want %d", len(result.SCTs), len(want))
+	}
+	for _, s := range result.SCTs {
+		if s.IssuanceDelay != want[s.Source] {
+			t.Errorf("%v SCT: IssuanceDelay = %v, want %v", s.Source, s.IssuanceDelay, want[s.Source])
+		}
+	}
+}
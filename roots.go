@@ -0,0 +1,81 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	ctclient "github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+// LogsAcceptingRoot returns the usable logs accepting certificates chaining to the root with the
+// given DER encoding, using the default checker. See (*checker).LogsAcceptingRoot.
+func LogsAcceptingRoot(ctx context.Context, rootDER []byte) ([]*loglist2.Log, error) {
+	return GetDefaultChecker().LogsAcceptingRoot(ctx, rootDER)
+}
+
+// LogsAcceptingRoot asks every usable log in the checker's log list for its accepted roots
+// (get-roots), and returns the logs which list the root with the given DER encoding, e.g. to
+// decide where a CA should submit its precertificates. Every log is asked; if some fail to
+// answer, the logs found among the others are returned along with an error reporting the
+// failures.
+func (c *checker) LogsAcceptingRoot(ctx context.Context, rootDER []byte) ([]*loglist2.Log, error) {
+	if len(rootDER) == 0 {
+		return nil, errors.New("no root certificate")
+	}
+
+	ll := c.logList().SelectByStatus([]loglist2.LogStatus{loglist2.UsableLogStatus})
+
+	var accepting []*loglist2.Log
+	var failed int
+	var firstErr error
+	for _, op := range ll.Operators {
+		for _, ctLog := range op.Logs {
+			if err := ctx.Err(); err != nil {
+				return accepting, err
+			}
+
+			ok, err := c.logAcceptsRoot(ctx, ctLog, rootDER)
+			if err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if ok {
+				accepting = append(accepting, ctLog)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return accepting, fmt.Errorf("failed to get accepted roots from %d logs, first error: %v", failed, firstErr)
+	}
+	return accepting, nil
+}
+
+// logAcceptsRoot returns true if rootDER is among the roots accepted by ctLog.
+func (c *checker) logAcceptsRoot(ctx context.Context, ctLog *loglist2.Log, rootDER []byte) (bool, error) {
+	logInfo, err := c.logInfoForLog(ctLog)
+	if err != nil {
+		return false, err
+	}
+	client, ok := logInfo.Client.(*ctclient.LogClient)
+	if !ok {
+		return false, fmt.Errorf("client for log %q does not support get-roots", ctLog.Description)
+	}
+
+	roots, err := client.GetAcceptedRoots(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get accepted roots from log %q: %v", ctLog.Description, err)
+	}
+	for _, root := range roots {
+		if bytes.Equal(root.Data, rootDER) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,66 @@
+package sct
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist2"
+)
+
+// rootsServer returns a log server whose get-roots lists the given certificates.
+func rootsServer(roots ...[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/get-roots" {
+			http.NotFound(w, r)
+			return
+		}
+		var rsp ct.GetRootsResponse
+		for _, root := range roots {
+			rsp.Certificates = append(rsp.Certificates, base64.StdEncoding.EncodeToString(root))
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+}
+
+func TestLogsAcceptingRoot(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+
+	accepting := newTestLog(t, "Accepting Log")
+	srv := rootsServer(other.cert.Raw, ca.cert.Raw)
+	defer srv.Close()
+	accepting.log.URL = srv.URL
+
+	rejecting := newTestLog(t, "Rejecting Log")
+	otherSrv := rootsServer(other.cert.Raw)
+	defer otherSrv.Close()
+	rejecting.log.URL = otherSrv.URL
+
+	// Not usable, so not asked even though it accepts the root.
+	retired := newTestLog(t, "Retired Log")
+	retired.log.URL = srv.URL
+	retired.log.State = &loglist2.LogStates{Retired: &loglist2.LogState{Timestamp: time.Now()}}
+
+	c := newTestChecker(accepting, rejecting, retired)
+	logs, err := c.LogsAcceptingRoot(context.Background(), ca.cert.Raw)
+	if err != nil {
+		t.Fatalf("LogsAcceptingRoot: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Description != "Accepting Log" {
+		t.Errorf("got %d logs, want only the accepting one", len(logs))
+	}
+
+	// An unreachable log is reported, without hiding the others.
+	unreachable := newTestLog(t, "Unreachable Log")
+	c = newTestChecker(accepting, unreachable)
+	logs, err = c.LogsAcceptingRoot(context.Background(), ca.cert.Raw)
+	if err == nil || len(logs) != 1 {
+		t.Errorf("with an unreachable log: got %d logs, err %v; want the accepting log and an error", len(logs), err)
+	}
+}
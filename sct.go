@@ -3,8 +3,15 @@
 package sct
 
 import (
+	"bytes"
+	"container/list"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
@@ -12,11 +19,16 @@ import (
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/loglist2"
+	cttls "github.com/google/certificate-transparency-go/tls"
 	ctx509 "github.com/google/certificate-transparency-go/x509"
 	ctx509util "github.com/google/certificate-transparency-go/x509util"
-	//zocsp "github.com/zzylydx/zcrypto/x509/revocation/ocsp"
 )
 
+// ErrPrecertificateLeaf is returned when a connection's leaf certificate is a precertificate
+// (RFC 6962 s3.1). Precertificates carry the poison extension so that they are never served
+// over TLS; verifying one as a leaf would check its SCTs against the wrong entry type.
+var ErrPrecertificateLeaf = errors.New("leaf certificate is a precertificate")
+
 var (
 	defaultCheckerOnce sync.Once
 	defaultChecker     *checker
@@ -24,27 +36,98 @@ var (
 
 // checker performs SCT checks.
 type checker struct {
+	opts Options
+	// base is the checker this one was derived from by withOptions, which holds the log list,
+	// caches and issuer pool of both. It is nil for checkers holding their own.
+	base *checker
+
+	mu sync.RWMutex
 	ll *loglist2.LogList
+	// pinned is true for checkers made by NewPinnedChecker, whose log list is never refreshed.
+	pinned bool
+	// refreshedAt is when the log list was last fetched successfully, or zero if it never was.
+	refreshedAt time.Time
+	// logListValidators are the HTTP cache validators of the log list last fetched by
+	// RefreshLogList, if it still is the checker's list.
+	logListValidators cacheValidators
+	// logInfos caches the outcome of newLogInfoFromLog, including failures, by log KeyID.
+	logInfos map[[sha256.Size]byte]*logInfoEntry
+	// limiters throttles requests to each log, by log KeyID. Unlike logInfos, it survives
+	// log list changes, since the limits belong to the log servers.
+	limiters map[[sha256.Size]byte]*tokenBucket
+
+	issuersMu sync.RWMutex
+	// issuers holds known issuer certificates by subject key ID.
+	issuers map[string]*ctx509.Certificate
+	// fetchedIssuers caches the issuers downloaded from AIA URLs, by URL.
+	fetchedIssuers map[string]*ctx509.Certificate
+
+	discoveryMu sync.Mutex
+	// discovered caches the answers of the log discovery service by log KeyID, as elements of
+	// discoveryLRU, which orders them most recently used first.
+	discovered   map[[sha256.Size]byte]*list.Element
+	discoveryLRU *list.List
+}
+
+// NewChecker returns a checker verifying SCTs against the logs in ll, configured by opts.
+// It returns ErrEmptyLogList if ll has no logs.
+func NewChecker(ll *loglist2.LogList, opts Options) (*checker, error) {
+	if ll == nil {
+		return nil, errors.New("no log list")
+	}
+	if countLogs(ll) == 0 {
+		return nil, ErrEmptyLogList
+	}
+
+	return &checker{
+		ll:   ll,
+		opts: opts,
+	}, nil
+}
+
+// withOptions returns a checker configured by opts, sharing c's log list, caches, rate limiters
+// and issuer pool. Log clients are built from the options of the checker holding them, so opts
+// must only differ from c's in how SCTs are judged, e.g. StrictRFC6962.
+func (c *checker) withOptions(opts Options) *checker {
+	return &checker{base: c.shared(), opts: opts}
+}
+
+// shared returns the checker holding c's log list, caches and issuer pool: the checker c was
+// derived from, or c itself.
+func (c *checker) shared() *checker {
+	if c.base != nil {
+		return c.base
+	}
+	return c
 }
 
 // getDefaultChecker returns the default Checker, initializing it if needed.
+// If the fetched log list has no logs, its checks return ErrEmptyLogList.
 func GetDefaultChecker() *checker {
 	defaultCheckerOnce.Do(func() {
 		defaultChecker = &checker{
-			ll: newDefaultLogList(),
+			ll:          newDefaultLogList(),
+			refreshedAt: time.Now(),
 		}
 	})
 
 	return defaultChecker
 }
 
-// CheckConnectionState examines SCTs (both embedded and in the TLS extension) and returns
-// nil if at least one of them is valid.
+// CheckConnectionState examines SCTs (embedded, in the TLS extension and in the stapled OCSP
+// response) and returns nil if at least one of them is valid.
 func CheckConnectionState(state *tls.ConnectionState) error {
-	return GetDefaultChecker().checkConnectionState(state)
+	return GetDefaultChecker().CheckConnectionState(state)
 }
 
-func (c *checker) checkConnectionState(state *tls.ConnectionState) error {
+// CheckConnectionState examines SCTs (embedded, in the TLS extension and in the stapled OCSP
+// response) and returns nil if at least one of them is valid. With Options.RequireEmbedded,
+// only embedded SCTs count, and enough of them are needed to meet the CT policy.
+//
+// state is a snapshot: it holds the certificates of the handshake that preceded the call to
+// tls.Conn.ConnectionState, not of any later renegotiation. To check a live connection, see
+// CheckConn.
+func (c *checker) CheckConnectionState(state *tls.ConnectionState) error {
 	if state == nil {
 		return errors.New("no TLS connection state")
 	}
@@ -53,15 +136,39 @@ func (c *checker) checkConnectionState(state *tls.ConnectionState) error {
 		return errors.New("no peer certificates in TLS connection state")
 	}
 
+	if err := c.checkStateSize(state); err != nil {
+		return err
+	}
+
 	chain, err := BuildCertificateChain(state.PeerCertificates) // 构建证书链
 	if err != nil {
 		return err
 	}
+	if chain[0].IsPrecertificate() {
+		return ErrPrecertificateLeaf
+	}
+
+	if c.ctNotApplicable(state, chain[0]) {
+		return ErrCTNotApplicable
+	}
+
+	if err := c.checkLogList(); err != nil {
+		return err
+	}
+
+	if c.opts.RequireEmbedded {
+		return c.checkEmbeddedRequirement(chain)
+	}
 
 	lastError := errors.New("no Signed Certificate Timestamps found")
 
+	// SCTs from the TLS extension and the OCSP response both cover the final certificate, so
+	// they share one Merkle leaf. Verification sets each SCT's timestamp on the leaf before use,
+	// so it can be shared by SCTs checked one after another.
+	merkleLeaf, leafErr := ct.MerkleTreeLeafFromChain(chain, ct.X509LogEntryType, 0)
+
 	// SCTs provided in the TLS handshake.
-	if err = c.checkTLSSCTs(state.SignedCertificateTimestamps, chain); err != nil {
+	if err = c.checkTLSSCTs(state.SignedCertificateTimestamps, merkleLeaf, leafErr); err != nil {
 		lastError = err
 	} else {
 		return nil
@@ -74,36 +181,328 @@ func (c *checker) checkConnectionState(state *tls.ConnectionState) error {
 		return nil
 	}
 
-	// TODO(mberhault): check SCTs in OSCP response.
-	// OcspStapling sct verify
-	// ocsp和tls方式一样
-	//ocspResponse, err := zocsp.ConvertResponse(string(state.OCSPResponse))
-	//if err != nil {
-	//	return nil
-	//}
-	//var sctListByte [][]byte
-	//sctListByte, err = zocsp.ParseSCTListFromOcspResponseByte(ocspResponse)
-	//if err = c.checkOcspSCTs(sctListByte, chain); err != nil {
-	//	lastError = err
-	//} else {
-	//	return nil
-	//}
-	//
+	// Check SCTs in the stapled OCSP response.
+	if len(state.OCSPResponse) > 0 {
+		scts, err := parseOCSPSCTs(state.OCSPResponse, state.PeerCertificates[0])
+		if err == nil && scts != nil {
+			err = c.checkOcspSCTs(scts, merkleLeaf, leafErr)
+			if err == nil {
+				return nil
+			}
+		}
+		if err != nil {
+			lastError = err
+		}
+	}
+
 	return lastError
 }
 
-// Check SCTs provided with the TLS handshake. Returns an error if no SCT is valid.
-func (c *checker) checkTLSSCTs(scts [][]byte, chain []*ctx509.Certificate) error {
-	if len(scts) == 0 {
-		return errors.New("no SCTs in SSL handshake")
+// CheckConnectionStateDetailed verifies every SCT (embedded, in the TLS extension and in the
+// stapled OCSP response) using the default checker, and returns the outcome for each.
+func CheckConnectionStateDetailed(state *tls.ConnectionState) (*Result, error) {
+	return GetDefaultChecker().CheckConnectionStateDetailed(state)
+}
+
+// CheckConnectionStateDetailedContext is like CheckConnectionStateDetailed, bounded by ctx.
+// See (*checker).CheckConnectionStateDetailedContext.
+func CheckConnectionStateDetailedContext(ctx context.Context, state *tls.ConnectionState) (*Result, error) {
+	return GetDefaultChecker().CheckConnectionStateDetailedContext(ctx, state)
+}
+
+// CheckConnectionStateDetailed verifies every SCT (embedded, in the TLS extension and in the
+// stapled OCSP response) and returns the outcome for each. Unlike CheckConnectionState, it does
+// not stop at the first valid SCT. An error is returned only if the connection state cannot be
+// examined at all; a malformed OCSP response is reported as a warning.
+func (c *checker) CheckConnectionStateDetailed(state *tls.ConnectionState) (*Result, error) {
+	return c.checkConnectionStateDetailed(nil, state)
+}
+
+// CheckConnectionStateDetailedContext is like CheckConnectionStateDetailed, but stops verifying
+// SCTs once ctx is done. It then returns the results of the SCTs verified so far along with
+// ctx.Err(), e.g. context.DeadlineExceeded, so time-bounded scans keep their partial results.
+// An SCT whose verification was interrupted is left out, since its outcome is unreliable.
+func (c *checker) CheckConnectionStateDetailedContext(ctx context.Context, state *tls.ConnectionState) (*Result, error) {
+	return c.checkConnectionStateDetailed(&checkParams{ctx: ctx}, state)
+}
+
+// checkParams holds per-call parameters of a detailed check. A nil *checkParams means defaults.
+type checkParams struct {
+	// ctx, if non-nil, bounds the check.
+	ctx context.Context
+	// operators, if non-nil, restricts verification to SCTs from logs run by these operators.
+	operators map[string]bool
+	// timings, if non-nil, accumulates the time spent in each verification phase.
+	timings *PhaseTimings
+}
+
+// context returns the context bounding the check.
+func (p *checkParams) context() context.Context {
+	if p == nil || p.ctx == nil {
+		return context.Background()
 	}
+	return p.ctx
+}
+
+// startPhase starts timing a verification phase, and returns the function ending it. Nothing is
+// timed unless p records timings.
+func (p *checkParams) startPhase(ph phase) func() {
+	if p == nil || p.timings == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { p.timings.add(ph, time.Since(start)) }
+}
 
+// skipOperator returns true if SCTs from logs run by the named operator are to be skipped. The
+// empty name stands for logs missing from the log list, which no selected operator runs.
+func (p *checkParams) skipOperator(name string) bool {
+	return p != nil && p.operators != nil && (name == "" || !p.operators[name])
+}
+
+func (c *checker) checkConnectionStateDetailed(p *checkParams, state *tls.ConnectionState) (*Result, error) {
+	if state == nil {
+		return nil, errors.New("no TLS connection state")
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no peer certificates in TLS connection state")
+	}
+
+	if err := c.checkStateSize(state); err != nil {
+		return nil, err
+	}
+
+	if c.opts.RecordPhaseTimings {
+		if p == nil {
+			p = &checkParams{}
+		}
+		p.timings = &PhaseTimings{}
+	}
+
+	endChainBuild := p.startPhase(phaseChainBuild)
+	chain, err := BuildCertificateChain(state.PeerCertificates)
+	endChainBuild()
+	if err != nil {
+		return nil, err
+	}
+	if chain[0].IsPrecertificate() {
+		return nil, ErrPrecertificateLeaf
+	}
+
+	result := &Result{
+		TLSExtension: tlsExtensionStatus(state.SignedCertificateTimestamps),
+		Validity:     certValidity(chain[0], c.opts.now()),
+		ShortLived:   IsShortLived(chain[0]),
+		Warnings:     embeddedSCTWarnings(chain[0]),
+	}
+	if c.ctNotApplicable(state, chain[0]) {
+		result.NotApplicable = true
+		return result, nil
+	}
+	if err := c.checkLogList(); err != nil {
+		return nil, err
+	}
+	if p != nil {
+		result.Timings = p.timings
+	}
+
+	result.SCTs = append(result.SCTs, c.verifyDeliveredSCTs(p, state.SignedCertificateTimestamps, chain, SourceTLSExtension)...)
+
+	if len(state.OCSPResponse) > 0 {
+		scts, ocspErr := parseOCSPSCTs(state.OCSPResponse, state.PeerCertificates[0])
+		switch {
+		case ocspErr != nil:
+			result.Warnings = append(result.Warnings, ocspErr.Error())
+		case scts != nil && len(scts) == 0:
+			result.Warnings = append(result.Warnings, "empty SCT list in OCSP response")
+		}
+		result.SCTs = append(result.SCTs, c.verifyDeliveredSCTs(p, scts, chain, SourceOCSP)...)
+	}
+
+	result.SCTs = append(result.SCTs, c.verifyEmbeddedSCTs(p, chain[0], c.issuerFor(p.context(), chain))...)
+	setIssuanceDelays(result.SCTs, chain[0])
+
+	if err := p.context().Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// verifyDeliveredSCTs verifies SCTs delivered alongside the leaf chain[0] by source, via TLS or
+// OCSP, which are signed over the leaf itself.
+func (c *checker) verifyDeliveredSCTs(p *checkParams, scts [][]byte, chain []*ctx509.Certificate, source SCTSource) []*SCTResult {
 	merkleLeaf, err := ct.MerkleTreeLeafFromChain(chain, ct.X509LogEntryType, 0)
+	return c.verifySerializedSCTs(p, serializedSCTs(scts), merkleLeaf, err, source)
+}
+
+// serializedSCTs wraps each TLS-encoded SCT for verifySerializedSCTs.
+func serializedSCTs(scts [][]byte) []ctx509.SerializedSCT {
+	serialized := make([]ctx509.SerializedSCT, len(scts))
+	for i, sct := range scts {
+		serialized[i] = ctx509.SerializedSCT{Val: sct}
+	}
+	return serialized
+}
+
+// verifyEmbeddedSCTs verifies the SCTs embedded in leaf, which was issued by issuer.
+// A nil issuer is recorded as an error against each SCT.
+func (c *checker) verifyEmbeddedSCTs(p *checkParams, leaf, issuer *ctx509.Certificate) []*SCTResult {
+	return c.verifyEmbeddedSCTList(p, leaf.SCTList.SCTList, leaf, issuer)
+}
+
+// verifyEmbeddedSCTList verifies scts, SCTs signed over the precertificate of leaf, as
+// verifyEmbeddedSCTs does for those embedded in leaf.
+func (c *checker) verifyEmbeddedSCTList(p *checkParams, scts []ctx509.SerializedSCT, leaf, issuer *ctx509.Certificate) []*SCTResult {
+	if len(scts) == 0 {
+		return nil
+	}
+
+	var merkleLeaf, altLeaf *ct.MerkleTreeLeaf
+	var err error
+	if issuer == nil {
+		err = errNoIssuer
+	} else if err = c.checkIssuerSignature(leaf, issuer); err == nil {
+		merkleLeaf, altLeaf, err = embeddedMerkleLeaves(leaf, issuer)
+	}
+
+	results := c.verifySerializedSCTs(p, scts, merkleLeaf, err, SourceEmbedded)
+
+	// Retry the SCTs whose signature failed against the alternate precertificate encoding, and
+	// explain the signature failures that remain.
+	for i, result := range results {
+		if !isSignatureError(result.Err) || p.context().Err() != nil {
+			continue
+		}
+		if altLeaf != nil {
+			retry := c.verifySerializedSCT(p, &ctx509.SerializedSCT{Val: result.Raw}, altLeaf, nil, SourceEmbedded)
+			if retry != nil && !isSignatureError(retry.Err) {
+				retry.Warnings = append(retry.Warnings, altEncodingWarning)
+				results[i] = retry
+				continue
+			}
+		}
+		result.Err = diagnoseEmbeddedSignature(result.Err, leaf, merkleLeaf)
+	}
+	return results
+}
+
+// verifySerializedSCTs verifies each SCT against merkleLeaf. If the leaf could not be built,
+// leafErr is recorded against every SCT instead. Skipped SCTs are left out.
+// Once p's context is done, the remaining SCTs, and any whose verification it interrupted, are
+// left out too.
+func (c *checker) verifySerializedSCTs(p *checkParams, scts []ctx509.SerializedSCT, merkleLeaf *ct.MerkleTreeLeaf, leafErr error, source SCTSource) []*SCTResult {
+	ctx := p.context()
+	n := c.opts.sctsToCheck(len(scts))
+	var results []*SCTResult
+	for i := range scts[:n] {
+		if ctx.Err() != nil {
+			return results
+		}
+		result := c.verifySerializedSCT(p, &scts[i], merkleLeaf, leafErr, source)
+		if ctx.Err() != nil {
+			return results
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+
+	// The SCTs beyond Options.MaxSCTsToCheck are reported, but not verified.
+	for i := range scts[n:] {
+		results = append(results, &SCTResult{
+			Source: source,
+			Raw:    scts[n+i].Val,
+			Err:    fmt.Errorf("not verified: only the first %d of %d SCTs from the %s source are checked", n, len(scts), source),
+		})
+	}
+	return results
+}
+
+// verifySerializedSCT verifies one SCT, or returns nil if p says to skip it.
+func (c *checker) verifySerializedSCT(p *checkParams, x509SCT *ctx509.SerializedSCT, merkleLeaf *ct.MerkleTreeLeaf, leafErr error, source SCTSource) *SCTResult {
+	endParse := p.startPhase(phaseSCTParse)
+	sct, err := ctx509util.ExtractSCT(x509SCT)
+	endParse()
 	if err != nil {
-		return err
+		return &SCTResult{Source: source, Raw: x509SCT.Val, Err: err}
+	}
+
+	result := c.verifyDecodedSCT(p.context(), p, sct, merkleLeaf, leafErr, source)
+	if result != nil {
+		result.Raw = x509SCT.Val
 	}
+	return result
+}
+
+// verifyDecodedSCT resolves the log of a decoded SCT and verifies it, or returns nil if p says to skip it.
+func (c *checker) verifyDecodedSCT(ctx context.Context, p *checkParams, sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf, leafErr error, source SCTSource) *SCTResult {
+	result := &SCTResult{Source: source}
+	result.LogID = hex.EncodeToString(sct.LogID.KeyID[:])
+	result.Timestamp = ct.TimestampToTime(sct.Timestamp)
 
-	for _, sct := range scts {
+	ctLog, operator := findLogByKeyHash(c.logList(), sct.LogID.KeyID)
+	if ctLog == nil && c.checkLogList() != nil {
+		result.Err = ErrEmptyLogList
+		return result
+	}
+	if ctLog == nil {
+		if p.skipOperator("") {
+			return nil
+		}
+		result.Err = fmt.Errorf("%w with KeyID %x (%s)", errUnknownLog, sct.LogID, c.logListDiagnostics())
+		if c.opts.LogDiscoveryURL != "" {
+			result.Err = c.discoveredSCTError(ctx, result, sct, merkleLeaf, result.Err)
+		}
+		return result
+	}
+	if p.skipOperator(operator.Name) {
+		return nil
+	}
+	result.LogDescription = ctLog.Description
+	result.Operator = operator.Name
+	result.LogStatus = ctLog.State.LogStatus()
+
+	if c.opts.OnlyUsableLogs && result.LogStatus != loglist2.UsableLogStatus {
+		result.Err = fmt.Errorf("log %q exists but is no longer usable (state %v)", ctLog.Description, result.LogStatus)
+		return result
+	}
+
+	if result.LogStatus == loglist2.PendingLogStatus {
+		if !c.opts.AcceptPendingLogs {
+			result.Err = fmt.Errorf("log %q is pending, not yet qualified", ctLog.Description)
+			return result
+		}
+		result.Warnings = append(result.Warnings, fmt.Sprintf("SCT from log %q, which is pending, not yet qualified", ctLog.Description))
+	}
+
+	if mmd := time.Duration(ctLog.MMD) * time.Second; c.opts.MaxLogMMD > 0 && mmd > c.opts.MaxLogMMD {
+		result.Err = fmt.Errorf("log %q declares an MMD of %v, above the configured ceiling of %v", ctLog.Description, mmd, c.opts.MaxLogMMD)
+		return result
+	}
+
+	if leafErr != nil {
+		result.Err = leafErr
+		return result
+	}
+
+	result.Err = c.verifySCT(ctx, p, result, sct, merkleLeaf, ctLog)
+	return result
+}
+
+// Check SCTs provided with the TLS handshake against the X509 Merkle leaf, or leafErr if it could
+// not be built. Returns an error if no SCT is valid.
+func (c *checker) checkTLSSCTs(scts [][]byte, merkleLeaf *ct.MerkleTreeLeaf, leafErr error) error {
+	if len(scts) == 0 {
+		return errors.New("no SCTs in SSL handshake")
+	}
+
+	if leafErr != nil {
+		return leafErr
+	}
+
+	n := c.opts.sctsToCheck(len(scts))
+	for _, sct := range scts[:n] {
 		x509SCT := &ctx509.SerializedSCT{Val: sct}
 		_, err := c.checkOneSCT(x509SCT, merkleLeaf)
 		if err == nil {
@@ -112,6 +511,9 @@ func (c *checker) checkTLSSCTs(scts [][]byte, chain []*ctx509.Certificate) error
 		}
 	}
 
+	if n < len(scts) {
+		return fmt.Errorf("no valid SCT among the first %d of %d in SSL handshake", n, len(scts))
+	}
 	return errors.New("no valid SCT in SSL handshake")
 }
 
@@ -122,36 +524,54 @@ func (c *checker) checkCertSCTs(chain []*ctx509.Certificate) error {
 		return errors.New("no SCTs in leaf certificate")
 	}
 
-	if len(chain) < 2 {
-		// TODO(mberhault): optionally fetch issuer from IssuingCertificateURL.
-		return errors.New("no issuer certificate in chain")
+	issuer := c.issuerFor(context.Background(), chain)
+	if issuer == nil {
+		return errNoIssuer
 	}
-	issuer := chain[1]
 
-	merkleLeaf, err := ct.MerkleTreeLeafForEmbeddedSCT([]*ctx509.Certificate{leaf, issuer}, 0)
+	if err := c.checkIssuerSignature(leaf, issuer); err != nil {
+		return err
+	}
+
+	merkleLeaf, altLeaf, err := embeddedMerkleLeaves(leaf, issuer)
 	if err != nil {
 		return err
 	}
 
-	for _, sct := range leaf.SCTList.SCTList {
+	scts := leaf.SCTList.SCTList
+	n := c.opts.sctsToCheck(len(scts))
+	for _, sct := range scts[:n] {
 		_,err := c.checkOneSCT(&sct, merkleLeaf)
+		if isSignatureError(err) && altLeaf != nil {
+			// Only a bad signature may be due to the precertificate encoding.
+			_, err = c.checkOneSCT(&sct, altLeaf)
+		}
 		if err == nil {
 			// Valid: return early.
 			return nil
 		}
 	}
 
+	if n < len(scts) {
+		return fmt.Errorf("no valid SCT among the first %d of %d in leaf certificate", n, len(scts))
+	}
 	return errors.New("no valid SCT in SSL handshake")
 }
 
-// Check SCTs provided with the TLS handshake. Returns an error if no SCT is valid.
-func (c *checker) checkOcspSCTs(scts [][]byte, chain []*ctx509.Certificate) error {
-	merkleLeaf, err := ct.MerkleTreeLeafFromChain(chain, ct.X509LogEntryType, 0)
-	if err != nil {
-		return err
+// Check SCTs provided in a stapled OCSP response. Returns an error if no SCT is valid.
+// Like SCTs from the TLS extension, these cover the final certificate (an X509 entry),
+// not the precertificate that embedded SCTs cover, and are checked against the same leaf.
+func (c *checker) checkOcspSCTs(scts [][]byte, merkleLeaf *ct.MerkleTreeLeaf, leafErr error) error {
+	if len(scts) == 0 {
+		return errors.New("no SCTs in OCSP response")
+	}
+
+	if leafErr != nil {
+		return leafErr
 	}
 
-	for _, sct := range scts {
+	n := c.opts.sctsToCheck(len(scts))
+	for _, sct := range scts[:n] {
 		x509SCT := &ctx509.SerializedSCT{Val: sct}
 		_,err := c.checkOneSCT(x509SCT, merkleLeaf)
 		if err == nil {
@@ -160,43 +580,209 @@ func (c *checker) checkOcspSCTs(scts [][]byte, chain []*ctx509.Certificate) erro
 		}
 	}
 
-	return errors.New("no valid SCT in SSL handshake")
+	if n < len(scts) {
+		return fmt.Errorf("no valid SCT among the first %d of %d in OCSP response", n, len(scts))
+	}
+	return errors.New("no valid SCT in OCSP response")
 }
 
 func (c *checker) checkOneSCT(x509SCT *ctx509.SerializedSCT, merkleLeaf *ct.MerkleTreeLeaf) (string, error) {
-	sct, err := ctx509util.ExtractSCT(x509SCT) // 反序列化sct
+	result := c.verifySerializedSCT(nil, x509SCT, merkleLeaf, nil, SourceTLSExtension)
+	if result.Err != nil {
+		return "", result.Err
+	}
+
+	return result.LogDescription, nil
+}
+
+// VerifyDecodedSCT verifies an SCT already decoded, e.g. with ExtractSCT, against the log list:
+// it resolves the issuing log, then checks the SCT's signature over merkleLeaf and its inclusion
+// in the log. ctx bounds the inclusion check.
+func (c *checker) VerifyDecodedSCT(ctx context.Context, sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf) error {
+	if sct == nil {
+		return errors.New("no SCT to verify")
+	}
+	if merkleLeaf == nil {
+		return errors.New("no Merkle tree leaf to verify against")
+	}
+
+	return c.verifyDecodedSCT(ctx, nil, sct, merkleLeaf, nil, SourceTLSExtension).Err
+}
+
+// VerifyOneSCT verifies a single serialized SCT against the given log, bypassing the log list.
+// The log need not appear in any log list, but the SCT must have been issued by it.
+func VerifyOneSCT(serialized *ctx509.SerializedSCT, merkleLeaf *ct.MerkleTreeLeaf, log *loglist2.Log) error {
+	if merkleLeaf == nil {
+		return errors.New("no Merkle tree leaf to verify against")
+	}
+	if log == nil {
+		return errors.New("no log to verify against")
+	}
+
+	sct, err := ctx509util.ExtractSCT(serialized)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	ctLog := c.ll.FindLogByKeyHash(sct.LogID.KeyID) // 找到对应的ct log
-	if ctLog == nil {
-		return "", fmt.Errorf("no log found with KeyID %x", sct.LogID)
+	if !bytes.Equal(sct.LogID.KeyID[:], log.LogID) {
+		return fmt.Errorf("SCT was issued by log with KeyID %x, not by log %s", sct.LogID.KeyID, log.Description)
+	}
+
+	return (&checker{}).verifySCT(context.Background(), nil, &SCTResult{}, sct, merkleLeaf, log)
+}
+
+// verifySCT checks the signature of a decoded SCT issued by ctLog, and its inclusion in that log.
+// Details beyond pass or fail are recorded in result, and phase timings in p.
+func (c *checker) verifySCT(ctx context.Context, p *checkParams, result *SCTResult, sct *ct.SignedCertificateTimestamp, merkleLeaf *ct.MerkleTreeLeaf, ctLog *loglist2.Log) error {
+	if c.opts.rejectUnknownVersions() && sct.SCTVersion != ct.V1 {
+		return fmt.Errorf("unsupported SCT version %v from log %s", sct.SCTVersion, ctLog.Description)
+	}
+
+	if c.opts.rejectExtensions() && len(sct.Extensions) > 0 {
+		return fmt.Errorf("SCT from log %s has non-empty extensions", ctLog.Description)
+	}
+
+	// A log cannot have issued an SCT after the present; allow for clock skew only.
+	if ahead := ct.TimestampToTime(sct.Timestamp).Sub(c.opts.now()); ahead > c.opts.maxClockSkew() {
+		return fmt.Errorf("SCT from log %s is %w by %v", ctLog.Description, errFutureTimestamp, ahead.Round(time.Second))
+	}
+
+	if c.opts.requireLogStateAtIssuance() {
+		if err := checkLogStateAt(ctLog, ct.TimestampToTime(sct.Timestamp)); err != nil {
+			return err
+		}
 	}
-	logDescription := ctLog.Description
 
-	logInfo, err := newLogInfoFromLog(ctLog)
+	logInfo, err := c.logInfoForLog(ctLog)
 	if err != nil {
-		return "", fmt.Errorf("could not create client for log %s", ctLog.Description) // 不懂
+		return fmt.Errorf("could not create client for log %s: %w", ctLog.Description, err)
+	}
+
+	endSignature := p.startPhase(phaseSignatureVerify)
+	// Cheap sanity check before the signature itself: a mismatch means the SCT is malformed or tampered with.
+	if err = checkSignatureAlgorithm(sct, logInfo.Verifier.PubKey); err != nil {
+		endSignature()
+		return fmt.Errorf("log %s: %v", ctLog.Description, err)
 	}
 
 	err = logInfo.VerifySCTSignature(*sct, *merkleLeaf) // 验证签名
+	endSignature()
 	if err != nil {
-		return "", err
+		return signatureError{err}
+	}
+
+	// A shard only accepts certificates expiring within its interval; an SCT from the wrong one is
+	// suspicious but still signed by the log.
+	if ctLog.TemporalInterval != nil {
+		notAfter, err := merkleLeafNotAfter(merkleLeaf)
+		if err == nil {
+			err = checkTemporalInterval(ctLog, notAfter)
+		}
+		if err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+		}
 	}
 
-	_, err = logInfo.VerifyInclusion(context.Background(), *merkleLeaf, sct.Timestamp)
+	endInclusion := p.startPhase(phaseInclusionFetch)
+	sth, err := c.verifyInclusion(ctx, logInfo, *merkleLeaf, sct.Timestamp)
+	endInclusion()
 	if err != nil {
-		age := time.Since(ct.TimestampToTime(sct.Timestamp))
-		if age >= logInfo.MMD {
-			return "", fmt.Errorf("failed to verify inclusion in log %q", ctLog.Description)
+		var consistencyErr *LogConsistencyError
+		if errors.As(err, &consistencyErr) {
+			return err
+		}
+
+		if c.opts.requireInclusion() {
+			return fmt.Errorf("%w in log %q: %v", errInclusionUnproven, ctLog.Description, err)
+		}
+
+		age := c.opts.now().Sub(ct.TimestampToTime(sct.Timestamp))
+		if c.opts.FailOnEntryNotFound && errors.Is(err, errEntryNotFound) && age >= c.opts.entryNotFoundGrace() {
+			return fmt.Errorf("log %q has no entry for the SCT (SCT age %v): %w", ctLog.Description, age.Round(time.Second), errEntryNotFound)
+		}
+
+		if c.opts.WarnOnInclusionFailure {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("inclusion in log %q unproven (SCT age %v, MMD %v): %v",
+				ctLog.Description, age.Round(time.Second), logInfo.MMD, err))
+			return nil
+		}
+
+		if age >= c.opts.recentSCTGrace(logInfo.MMD) {
+			return fmt.Errorf("%w in log %q: %v", errInclusionUnproven, ctLog.Description, err)
 		}
 
 		// TODO(mberhault): option to fail on timestamp too recent.
-		return logDescription, nil
+		return nil
+	}
+	result.InclusionVerified = true
+	if c.opts.RecordInclusionSTH {
+		result.InclusionSTH = sth
+	}
+
+	return nil
+}
+
+// Causes of SCT failures, wrapped by the errors recorded against SCTs so that failureReason and
+// metricReason can tell them apart. Bad signatures are marked with signatureError instead.
+var (
+	errUnknownLog        = errors.New("no log found")
+	errNoIssuer          = errors.New("no issuer certificate in chain")
+	errFutureTimestamp   = errors.New("timestamped in the future")
+	errInclusionUnproven = errors.New("failed to verify inclusion")
+)
+
+// signatureError marks an SCT whose signature does not verify over the Merkle leaf, as opposed to
+// one rejected by the checks around it.
+type signatureError struct {
+	err error
+}
+
+func (e signatureError) Error() string { return e.err.Error() }
+
+func (e signatureError) Unwrap() error { return e.err }
+
+// isSignatureError returns true if err, or an error it wraps, is a signatureError.
+func isSignatureError(err error) bool {
+	var sigErr signatureError
+	return errors.As(err, &sigErr)
+}
+
+// checkSignatureAlgorithm returns an error if the signature algorithm claimed by the SCT
+// cannot have been produced by the log's public key.
+func checkSignatureAlgorithm(sct *ct.SignedCertificateTimestamp, logKey crypto.PublicKey) error {
+	if isRSAPSS(sct.Signature.Algorithm) {
+		// The CT library only verifies PKCS#1 v1.5 RSA signatures.
+		return errors.New("RSA-PSS SCT signatures are not supported")
+	}
+
+	var want cttls.SignatureAlgorithm
+	switch logKey.(type) {
+	case *ecdsa.PublicKey:
+		want = cttls.ECDSA
+	case *rsa.PublicKey:
+		want = cttls.RSA
+	default:
+		return fmt.Errorf("unsupported log key type %T", logKey)
 	}
 
-	return logDescription, nil
+	if got := sct.Signature.Algorithm.Signature; got != want {
+		return fmt.Errorf("SCT signature algorithm does not match log key: SCT uses %v, log key is %v", got, want)
+	}
+
+	return nil
+}
+
+// isRSAPSS returns true if alg is one of the RSA-PSS signature schemes of RFC 8446 s4.2.3.
+// In the TLS 1.2 encoding used by SCTs, these use the "intrinsic" hash value 8.
+func isRSAPSS(alg cttls.SignatureAndHashAlgorithm) bool {
+	if alg.Hash != 8 {
+		return false
+	}
+	switch alg.Signature {
+	case 4, 5, 6, 9, 10, 11:
+		return true
+	}
+	return false
 }
 
 // use for webemail measurement, only check sct validity. true or false
@@ -226,11 +812,10 @@ func (c *checker) VerifyCertSCTs(sct *ctx509.SerializedSCT, chain []*ctx509.Cert
 		return "", false
 	}
 
-	if len(chain) < 2 {
-		// TODO(mberhault): optionally fetch issuer from IssuingCertificateURL.
+	issuer := c.issuerFor(context.Background(), chain)
+	if issuer == nil {
 		return "", false
 	}
-	issuer := chain[1]
 
 	merkleLeaf, err := ct.MerkleTreeLeafForEmbeddedSCT([]*ctx509.Certificate{leaf, issuer}, 0)
 	if err != nil {
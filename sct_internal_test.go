@@ -0,0 +1,775 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/loglist2"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+func TestCheckOneSCTSignatureAlgorithmMismatch(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	chain := mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert)
+	merkleLeaf := x509Leaf(t, chain)
+	c := newTestChecker(l)
+
+	sct := l.sign(t, merkleLeaf, time.Now())
+	if _, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: marshalSCT(t, sct)}, merkleLeaf); err != nil {
+		t.Fatalf("valid SCT rejected: %v", err)
+	}
+
+	// The log key is ECDSA; claim RSA instead.
+	sct.Signature.Algorithm.Signature = cttls.RSA
+	_, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: marshalSCT(t, sct)}, merkleLeaf)
+	if err == nil || !strings.Contains(err.Error(), "SCT signature algorithm does not match log key") {
+		t.Fatalf("expected signature algorithm mismatch, got %v", err)
+	}
+}
+
+func TestCheckOneSCTFutureTimestamp(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	c := newTestChecker(l)
+
+	skewed := marshalSCT(t, l.sign(t, merkleLeaf, time.Now().Add(time.Minute)))
+	if _, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: skewed}, merkleLeaf); err != nil {
+		t.Errorf("SCT within clock skew rejected: %v", err)
+	}
+
+	future := marshalSCT(t, l.sign(t, merkleLeaf, time.Now().Add(time.Hour)))
+	_, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: future}, merkleLeaf)
+	if err == nil || !strings.Contains(err.Error(), "in the future") {
+		t.Errorf("expected future timestamp rejection, got %v", err)
+	}
+
+	c.opts.MaxClockSkew = 2 * time.Hour
+	if _, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: future}, merkleLeaf); err != nil {
+		t.Errorf("SCT within configured clock skew rejected: %v", err)
+	}
+}
+
+func TestCheckOneSCTRSAPSS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := sha256.Sum256(der)
+	l := newTestLog(t, "RSA-PSS Log")
+	l.log.LogID = logID[:]
+	l.log.Key = der
+
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	sct := &ct.SignedCertificateTimestamp{
+		SCTVersion: ct.V1,
+		LogID:      ct.LogID{KeyID: logID},
+		Timestamp:  uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+	}
+	entry := ct.LogEntry{Leaf: *merkleLeaf}
+	entry.Leaf.TimestampedEntry.Timestamp = sct.Timestamp
+	data, err := ct.SerializeSCTSignatureInput(*sct, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(data)
+	sig, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rsa_pss_rsae_sha256 (0x0804).
+	sct.Signature = ct.DigitallySigned{
+		Algorithm: cttls.SignatureAndHashAlgorithm{Hash: 8, Signature: 4},
+		Signature: sig,
+	}
+
+	_, err = newTestChecker(l).checkOneSCT(&ctx509.SerializedSCT{Val: marshalSCT(t, sct)}, merkleLeaf)
+	if err == nil || !strings.Contains(err.Error(), "RSA-PSS SCT signatures are not supported") {
+		t.Fatalf("expected RSA-PSS to be reported as unsupported, got %v", err)
+	}
+}
+
+func TestVerifyOneSCT(t *testing.T) {
+	l := newTestLog(t, "Unlisted Log")
+	other := newTestLog(t, "Other Log")
+	ca := newTestCA(t)
+	chain := mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert)
+	merkleLeaf := x509Leaf(t, chain)
+	serialized := &ctx509.SerializedSCT{Val: marshalSCT(t, l.sign(t, merkleLeaf, time.Now()))}
+
+	if err := VerifyOneSCT(serialized, merkleLeaf, l.log); err != nil {
+		t.Fatalf("VerifyOneSCT against issuing log: %v", err)
+	}
+
+	if err := VerifyOneSCT(serialized, merkleLeaf, other.log); err == nil {
+		t.Fatal("VerifyOneSCT against a different log succeeded")
+	}
+
+	otherLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	if err := VerifyOneSCT(serialized, otherLeaf, l.log); err == nil {
+		t.Fatal("VerifyOneSCT against a different certificate succeeded")
+	}
+}
+
+func TestVerifyDecodedSCT(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	unknown := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert))
+	c := newTestChecker(l)
+	ctx := context.Background()
+
+	if err := c.VerifyDecodedSCT(ctx, l.sign(t, merkleLeaf, time.Now()), merkleLeaf); err != nil {
+		t.Errorf("valid decoded SCT rejected: %v", err)
+	}
+	if err := c.VerifyDecodedSCT(ctx, unknown.sign(t, merkleLeaf, time.Now()), merkleLeaf); err == nil {
+		t.Error("decoded SCT from an unknown log accepted")
+	}
+	if err := c.VerifyDecodedSCT(ctx, nil, merkleLeaf); err == nil {
+		t.Error("nil SCT accepted")
+	}
+
+	// The context bounds the inclusion check, which is required here.
+	c.opts.RequireInclusion = true
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.VerifyDecodedSCT(cancelled, l.sign(t, merkleLeaf, time.Now()), merkleLeaf); err == nil {
+		t.Error("inclusion check succeeded with a cancelled context")
+	}
+}
+
+func TestCheckOcspSCTsUsesX509Entry(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	chain := mustBuildChain(t, ca.issue(t, tmpl), ca.cert)
+	c := newTestChecker(l)
+
+	x509SCT := marshalSCT(t, l.sign(t, x509Leaf(t, chain), time.Now()))
+	if err := c.checkOcspSCTs([][]byte{x509SCT}, x509Leaf(t, chain), nil); err != nil {
+		t.Fatalf("OCSP-delivered SCT for the final certificate rejected: %v", err)
+	}
+
+	precertLeaf := ca.precertLeaf(t, tmpl)
+	precertSCT := marshalSCT(t, l.sign(t, precertLeaf, time.Now()))
+	if err := c.checkOcspSCTs([][]byte{precertSCT}, x509Leaf(t, chain), nil); err == nil {
+		t.Fatal("OCSP-delivered SCT over the precertificate accepted")
+	}
+	if _, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: x509SCT}, precertLeaf); err == nil {
+		t.Fatal("X509 entry SCT accepted on the embedded precertificate path")
+	}
+}
+
+func TestStrictRFC6962(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	chain := mustBuildChain(t, ca.issue(t, leafTemplate()), ca.cert)
+	merkleLeaf := x509Leaf(t, chain)
+
+	recent := newTestLog(t, "Recently Qualified Log")
+	recent.log.State = &loglist2.LogStates{Qualified: &loglist2.LogState{Timestamp: time.Now()}}
+	beforeQualified := recent.sign(t, merkleLeaf, time.Now().Add(-time.Hour))
+
+	pending := newTestLog(t, "Pending Log")
+	pending.log.State = &loglist2.LogStates{Pending: &loglist2.LogState{Timestamp: time.Now().Add(-time.Hour)}}
+
+	withExtensions := l.sign(t, merkleLeaf, time.Now())
+	withExtensions.Extensions = ct.CTExtensions{0x00, 0x01}
+	l.resign(t, merkleLeaf, withExtensions)
+
+	tests := []struct {
+		name    string
+		sct     *ct.SignedCertificateTimestamp
+		opts    Options
+		wantErr bool
+	}{
+		// The test log is unreachable, so inclusion can never be proven.
+		{"lenient inclusion", l.sign(t, merkleLeaf, time.Now()), Options{}, false},
+		{"required inclusion", l.sign(t, merkleLeaf, time.Now()), Options{RequireInclusion: true}, true},
+		{"strict inclusion", l.sign(t, merkleLeaf, time.Now()), Options{StrictRFC6962: true}, true},
+		{"lenient extensions", withExtensions, Options{}, false},
+		{"rejected extensions", withExtensions, Options{RejectExtensions: true}, true},
+		{"lenient log state", beforeQualified, Options{}, false},
+		{"SCT before log qualified", beforeQualified, Options{RequireLogStateAtIssuance: true}, true},
+		{"usable log only", l.sign(t, merkleLeaf, time.Now()), Options{OnlyUsableLogs: true}, false},
+		{"qualified log with usable logs only", recent.sign(t, merkleLeaf, time.Now()), Options{OnlyUsableLogs: true}, true},
+		{"pending log", pending.sign(t, merkleLeaf, time.Now()), Options{}, true},
+		{"accepted pending log", pending.sign(t, merkleLeaf, time.Now()), Options{AcceptPendingLogs: true}, false},
+		{"accepted pending log with state at issuance", pending.sign(t, merkleLeaf, time.Now()), Options{AcceptPendingLogs: true, RequireLogStateAtIssuance: true}, true},
+		{"old SCT within MMD", l.sign(t, merkleLeaf, time.Now().Add(-2*time.Hour)), Options{}, false},
+		{"old SCT past recent grace", l.sign(t, merkleLeaf, time.Now().Add(-2*time.Hour)), Options{RecentSCTGrace: time.Hour}, true},
+		{"old SCT within recent grace", l.sign(t, merkleLeaf, time.Now().Add(-30*time.Hour)), Options{RecentSCTGrace: 48 * time.Hour}, false},
+		{"no recent grace", l.sign(t, merkleLeaf, time.Now()), Options{RecentSCTGrace: -1}, true},
+		{"MMD under ceiling", l.sign(t, merkleLeaf, time.Now()), Options{MaxLogMMD: 48 * time.Hour}, false},
+		{"MMD over ceiling", l.sign(t, merkleLeaf, time.Now()), Options{MaxLogMMD: 12 * time.Hour}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChecker(l, recent, pending)
+			c.opts = test.opts
+			_, err := c.checkOneSCT(&ctx509.SerializedSCT{Val: marshalSCT(t, test.sct)}, merkleLeaf)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("checkOneSCT() error = %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxSCTsToCheck(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	unknownLog := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{
+			marshalSCT(t, unknownLog.sign(t, merkleLeaf, time.Now())),
+			marshalSCT(t, l.sign(t, merkleLeaf, time.Now())),
+		},
+	}
+
+	c := newTestChecker(l)
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Fatalf("CheckConnectionState without limit: %v", err)
+	}
+
+	c.opts.MaxSCTsToCheck = 1
+	if err := c.CheckConnectionState(state); err == nil {
+		t.Error("CheckConnectionState accepted a valid SCT beyond MaxSCTsToCheck")
+	}
+
+	// Detailed checks apply the limit to the TLS extension and embedded SCTs alike.
+	tmpl := leafTemplate()
+	precert := ca.precertLeaf(t, tmpl)
+	embedded := ca.issue(t, tmpl, unknownLog.sign(t, precert, time.Now()), l.sign(t, precert, time.Now()))
+	for _, state := range []*tls.ConnectionState{
+		state,
+		{PeerCertificates: []*x509.Certificate{embedded, ca.cert}},
+	} {
+		c.opts.MaxSCTsToCheck = 0
+		if result, err := c.CheckConnectionStateDetailed(state); err != nil || result.ValidCount() != 1 {
+			t.Fatalf("CheckConnectionStateDetailed without limit = %v, want 1 valid SCT", err)
+		}
+
+		c.opts.MaxSCTsToCheck = 1
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			t.Fatalf("CheckConnectionStateDetailed: %v", err)
+		}
+		if len(result.SCTs) != 2 || result.ValidCount() != 0 {
+			t.Fatalf("got %d SCTs, %d valid; want 2, none valid", len(result.SCTs), result.ValidCount())
+		}
+		if err := result.SCTs[1].Err; err == nil || !strings.Contains(err.Error(), "not verified") {
+			t.Errorf("SCT beyond MaxSCTsToCheck: error %v, want it reported as not verified", err)
+		}
+	}
+}
+
+func TestCheckConnectionStatePrecertificateLeaf(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.issuePrecert(t, leafTemplate()), ca.cert}}
+	c := newTestChecker(l)
+
+	if err := c.CheckConnectionState(state); err != ErrPrecertificateLeaf {
+		t.Errorf("CheckConnectionState = %v, want %v", err, ErrPrecertificateLeaf)
+	}
+	if _, err := c.CheckConnectionStateDetailed(state); err != ErrPrecertificateLeaf {
+		t.Errorf("CheckConnectionStateDetailed = %v, want %v", err, ErrPrecertificateLeaf)
+	}
+}
+
+func TestVerifyIssuerSignature(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	// Same name as ca, different key: the chain looks right but is mis-assembled.
+	impostor := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+	c.opts.VerifyIssuerSignature = true
+
+	if err := c.CheckConnectionState(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}); err != nil {
+		t.Errorf("CheckConnectionState with the right issuer: %v", err)
+	}
+
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, impostor.cert}}
+	if err := c.CheckConnectionState(state); err == nil || !strings.Contains(err.Error(), "not signed by issuer") {
+		t.Errorf("CheckConnectionState with the wrong issuer = %v, want a signature error", err)
+	}
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if len(result.SCTs) != 1 || result.SCTs[0].Err == nil || !strings.Contains(result.SCTs[0].Err.Error(), "not signed by issuer") {
+		t.Errorf("SCT results = %+v, want the issuer signature error", result.SCTs)
+	}
+}
+
+func TestRecordPhaseTimings(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{ca.issueWithEmbeddedSCTs(t, leafTemplate(), l), ca.cert},
+	}
+	c := newTestChecker(l)
+
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.Timings != nil {
+		t.Errorf("got timings %+v without RecordPhaseTimings", result.Timings)
+	}
+
+	c.opts.RecordPhaseTimings = true
+	result, err = c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if tm := result.Timings; tm == nil || tm.ChainBuild <= 0 || tm.SCTParse <= 0 || tm.SignatureVerify <= 0 || tm.InclusionFetch <= 0 {
+		t.Errorf("timings = %+v, want every phase timed", result.Timings)
+	}
+}
+
+func TestCheckLogStateAt(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	before, after := since.Add(-time.Hour), since.Add(time.Hour)
+	state := &loglist2.LogState{Timestamp: since}
+
+	tests := []struct {
+		name    string
+		states  *loglist2.LogStates
+		when    time.Time
+		wantErr bool
+	}{
+		{"qualified after", &loglist2.LogStates{Qualified: state}, after, false},
+		{"qualified before", &loglist2.LogStates{Qualified: state}, before, true},
+		{"usable before", &loglist2.LogStates{Usable: state}, before, false},
+		{"read-only before", &loglist2.LogStates{ReadOnly: &loglist2.ReadOnlyLogState{LogState: *state}}, before, false},
+		{"read-only after", &loglist2.LogStates{ReadOnly: &loglist2.ReadOnlyLogState{LogState: *state}}, after, true},
+		{"retired before", &loglist2.LogStates{Retired: state}, before, false},
+		{"retired after", &loglist2.LogStates{Retired: state}, after, true},
+		{"pending", &loglist2.LogStates{Pending: state}, after, true},
+		{"no state", nil, after, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkLogStateAt(&loglist2.Log{Description: "Test Log", State: test.states}, test.when)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("checkLogStateAt() error = %v, want error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemporalShardWarning(t *testing.T) {
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	// Certificates carry whole seconds, so match the encoded expiry exactly.
+	tmpl.NotAfter = tmpl.NotAfter.Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		interval *loglist2.TemporalInterval
+		wantWarn bool
+	}{
+		{"unsharded", nil, false},
+		{"inside", &loglist2.TemporalInterval{StartInclusive: tmpl.NotAfter.Add(-time.Hour), EndExclusive: tmpl.NotAfter.Add(time.Hour)}, false},
+		{"after", &loglist2.TemporalInterval{StartInclusive: tmpl.NotAfter.Add(time.Hour), EndExclusive: tmpl.NotAfter.Add(2 * time.Hour)}, true},
+		{"end exclusive", &loglist2.TemporalInterval{StartInclusive: tmpl.NotAfter.Add(-time.Hour), EndExclusive: tmpl.NotAfter}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newTestLog(t, "Shard Log")
+			l.log.TemporalInterval = test.interval
+			leaf := ca.issueWithEmbeddedSCTs(t, tmpl, l)
+
+			result, err := newTestChecker(l).CheckConnectionStateDetailed(&tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+			})
+			if err != nil {
+				t.Fatalf("CheckConnectionStateDetailed: %v", err)
+			}
+			if len(result.SCTs) != 1 || !result.SCTs[0].Valid() {
+				t.Fatalf("got %d SCTs, %d valid; want 1 valid", len(result.SCTs), result.ValidCount())
+			}
+			if gotWarn := len(result.SCTs[0].Warnings) > 0; gotWarn != test.wantWarn {
+				t.Errorf("warnings = %v, want warning: %v", result.SCTs[0].Warnings, test.wantWarn)
+			}
+		})
+	}
+}
+
+func TestCheckConnectionStateDetailed(t *testing.T) {
+	embeddedLog := newTestLog(t, "Embedded Log")
+	tlsLog := newTestLog(t, "TLS Log")
+	unknownLog := newTestLog(t, "Unknown Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), embeddedLog)
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{
+			marshalSCT(t, tlsLog.sign(t, merkleLeaf, time.Now())),
+			marshalSCT(t, unknownLog.sign(t, merkleLeaf, time.Now())),
+		},
+	}
+
+	result, err := newTestChecker(embeddedLog, tlsLog).CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if len(result.SCTs) != 3 {
+		t.Fatalf("got %d SCT results, want 3", len(result.SCTs))
+	}
+	if got := result.ValidCount(); got != 2 {
+		t.Errorf("ValidCount() = %d, want 2", got)
+	}
+
+	want := []struct {
+		source SCTSource
+		log    *testLog
+		valid  bool
+	}{
+		{SourceTLSExtension, tlsLog, true},
+		{SourceTLSExtension, unknownLog, false},
+		{SourceEmbedded, embeddedLog, true},
+	}
+	for i, w := range want {
+		got := result.SCTs[i]
+		if got.Source != w.source || got.Valid() != w.valid {
+			t.Errorf("SCT %d: source %v, valid %v (%v); want %v, valid %v", i, got.Source, got.Valid(), got.Err, w.source, w.valid)
+		}
+		if wantID := hex.EncodeToString(w.log.log.LogID); got.LogID != wantID {
+			t.Errorf("SCT %d: LogID = %q, want %q", i, got.LogID, wantID)
+		}
+	}
+
+	// Raw SCTs are the bytes as received.
+	for i, sct := range state.SignedCertificateTimestamps {
+		if !bytes.Equal(result.SCTs[i].Raw, sct) {
+			t.Errorf("SCT %d: Raw does not match the TLS extension", i)
+		}
+	}
+	if !bytes.Equal(result.SCTs[2].Raw, mustBuildChain(t, leaf)[0].SCTList.SCTList[0].Val) {
+		t.Error("embedded SCT: Raw does not match the certificate extension")
+	}
+}
+
+func TestCheckConnectionStateDetailedTLSExtension(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	c := newTestChecker(newTestLog(t, "Test Log"))
+
+	tests := []struct {
+		name string
+		scts [][]byte
+		want TLSExtensionStatus
+	}{
+		{"absent", nil, TLSExtensionAbsent},
+		{"empty", [][]byte{}, TLSExtensionEmpty},
+		{"present", [][]byte{{0x00}}, TLSExtensionPresent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := &tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+				SignedCertificateTimestamps: test.scts,
+			}
+			result, err := c.CheckConnectionStateDetailed(state)
+			if err != nil {
+				t.Fatalf("CheckConnectionStateDetailed: %v", err)
+			}
+			if result.TLSExtension != test.want {
+				t.Errorf("TLSExtension = %v, want %v", result.TLSExtension, test.want)
+			}
+		})
+	}
+}
+
+func TestWarnOnInclusionFailure(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	// Older than the log's MMD, and the test log is unreachable.
+	old := marshalSCT(t, l.sign(t, merkleLeaf, time.Now().Add(-48*time.Hour)))
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{old},
+	}
+
+	c := newTestChecker(l)
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 0 {
+		t.Fatal("unproven SCT past MMD accepted by default")
+	}
+
+	c.opts.WarnOnInclusionFailure = true
+	result, err = c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if result.ValidCount() != 1 || !result.HasWarnings() {
+		t.Fatalf("got %d valid SCTs, warnings %v; want 1 valid SCT with warnings", result.ValidCount(), result.HasWarnings())
+	}
+	if result.SCTs[0].InclusionVerified {
+		t.Error("InclusionVerified set for an unproven SCT")
+	}
+
+	c.opts.RequireInclusion = true
+	if result, _ = c.CheckConnectionStateDetailed(state); result.ValidCount() != 0 {
+		t.Error("unproven SCT accepted with RequireInclusion")
+	}
+}
+
+func TestCheckConnectionStateIPAddressLeaf(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.Subject = pkix.Name{}
+	tmpl.DNSNames = nil
+	tmpl.IPAddresses = []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}
+	leaf := ca.issueWithEmbeddedSCTs(t, tmpl, l)
+	chain := mustBuildChain(t, leaf, ca.cert)
+
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{marshalSCT(t, l.sign(t, x509Leaf(t, chain), time.Now()))},
+	}
+	c := newTestChecker(l)
+
+	result, err := c.CheckConnectionStateDetailed(state)
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if len(result.SCTs) != 2 || result.ValidCount() != 2 {
+		t.Errorf("got %d SCTs, %d valid; want 2 valid (embedded and TLS)", len(result.SCTs), result.ValidCount())
+	}
+	if err := c.CheckConnectionState(state); err != nil {
+		t.Errorf("CheckConnectionState: %v", err)
+	}
+	if got := ValidationLevel(chain[0]); got != UnknownValidationLevel.String() {
+		t.Errorf("ValidationLevel() = %q for a leaf without subject", got)
+	}
+}
+
+func TestCheckConnectionStateDetailedValidity(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}
+
+	tests := []struct {
+		now  time.Time
+		want CertValidity
+	}{
+		{time.Now(), ValidityCurrent},
+		{leaf.NotBefore.Add(-time.Minute), ValidityNotYetValid},
+		{leaf.NotAfter.Add(time.Minute), ValidityExpired},
+	}
+	for _, tt := range tests {
+		c := newTestChecker(newTestLog(t, "Test Log"))
+		now := tt.now
+		c.opts.Now = func() time.Time { return now }
+
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			t.Fatalf("CheckConnectionStateDetailed: %v", err)
+		}
+		if result.Validity != tt.want {
+			t.Errorf("at %v: validity %v, want %v", now, result.Validity, tt.want)
+		}
+	}
+}
+
+func TestCheckConnectionStateDetailedContextPartial(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The second log's server cancels the check while its SCT is being verified.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	first := newTestLog(t, "First Log")
+	interrupted := newTestLog(t, "Interrupted Log")
+	interrupted.log.URL = srv.URL
+	last := newTestLog(t, "Last Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	merkleLeaf := x509Leaf(t, mustBuildChain(t, leaf, ca.cert))
+	state := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+		SignedCertificateTimestamps: [][]byte{
+			marshalSCT(t, first.sign(t, merkleLeaf, time.Now())),
+			marshalSCT(t, interrupted.sign(t, merkleLeaf, time.Now())),
+			marshalSCT(t, last.sign(t, merkleLeaf, time.Now())),
+		},
+	}
+
+	result, err := newTestChecker(first, interrupted, last).CheckConnectionStateDetailedContext(ctx, state)
+	if err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if result == nil || len(result.SCTs) != 1 || result.SCTs[0].LogDescription != "First Log" || !result.SCTs[0].Valid() {
+		t.Errorf("got partial result %+v, want only the SCT verified before cancellation", result)
+	}
+}
+
+func TestAllowNoSCTsForPrivateRoots(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	private := newTestCA(t)
+	public := newTestCA(t)
+	c := newTestChecker(l)
+	c.opts.AllowNoSCTsForPrivateRoots = true
+	c.opts.PrivateRoots = x509.NewCertPool()
+	c.opts.PrivateRoots.AddCert(private.cert)
+
+	internal := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{private.issue(t, leafTemplate()), private.cert}}
+	if err := c.CheckConnectionState(internal); err != ErrCTNotApplicable {
+		t.Errorf("CheckConnectionState for a private root = %v, want %v", err, ErrCTNotApplicable)
+	}
+	result, err := c.CheckConnectionStateDetailed(internal)
+	if err != nil || !result.NotApplicable {
+		t.Errorf("CheckConnectionStateDetailed for a private root: NotApplicable = %v, err = %v; want true, nil", result != nil && result.NotApplicable, err)
+	}
+	for _, report := range []*Report{c.ComplianceReport(internal), c.AppleComplianceReport(internal)} {
+		if report.Compliant() || len(report.Violations) != 0 || report.Result == nil || !report.Result.NotApplicable {
+			t.Errorf("compliance report for a private root = %+v, want a not applicable result, neither compliant nor violating", report)
+		}
+	}
+	for _, policy := range []Policy{PolicyAtLeastOne, PolicyChrome, PolicyApple, PolicyStrictRFC} {
+		if result, err := c.CheckConnectionStatePolicy(internal, policy); err != ErrCTNotApplicable || result == nil {
+			t.Errorf("CheckConnectionStatePolicy(%v) for a private root = %v, want %v with the result", policy, err, ErrCTNotApplicable)
+		}
+	}
+	if _, result, err := c.StrongestPolicy(internal, nil); err != ErrCTNotApplicable || result == nil || !result.NotApplicable {
+		t.Errorf("StrongestPolicy for a private root = %v, want %v with the result", err, ErrCTNotApplicable)
+	}
+
+	// Other roots, and certificates with SCTs, are checked as usual.
+	external := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{public.issue(t, leafTemplate()), public.cert}}
+	if err := c.CheckConnectionState(external); err == nil || err == ErrCTNotApplicable {
+		t.Errorf("CheckConnectionState for a public root = %v, want a failure", err)
+	}
+	withSCTs := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{private.issueWithEmbeddedSCTs(t, leafTemplate(), l), private.cert}}
+	if err := c.CheckConnectionState(withSCTs); err != nil {
+		t.Errorf("CheckConnectionState for a private root with SCTs: %v", err)
+	}
+
+	c.opts.AllowNoSCTsForPrivateRoots = false
+	if err := c.CheckConnectionState(internal); err == nil || err == ErrCTNotApplicable {
+		t.Errorf("CheckConnectionState without the option = %v, want a failure", err)
+	}
+}
+
+func TestInputLimits(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+
+	for _, tc := range []struct {
+		name  string
+		state *tls.ConnectionState
+		set   func(*Options)
+		input string
+	}{
+		{
+			name:  "certificate",
+			state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}},
+			set:   func(o *Options) { o.MaxCertificateBytes = len(leaf.Raw) - 1 },
+			input: "certificate 0",
+		},
+		{
+			name: "SCT list",
+			state: &tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{leaf, ca.cert},
+				SignedCertificateTimestamps: [][]byte{make([]byte, 100)},
+			},
+			set:   func(o *Options) { o.MaxSCTListBytes = 100 },
+			input: "TLS extension SCT list",
+		},
+		{
+			name: "OCSP response",
+			state: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+				OCSPResponse:     make([]byte, DefaultMaxOCSPResponseBytes+1),
+			},
+			set:   func(o *Options) {},
+			input: "OCSP response",
+		},
+	} {
+		c.opts = Options{}
+		tc.set(&c.opts)
+		_, err := c.CheckConnectionStateDetailed(tc.state)
+		limitErr, ok := err.(*InputLimitError)
+		if !ok || limitErr.Input != tc.input {
+			t.Errorf("%s: CheckConnectionStateDetailed = %v, want an InputLimitError for %q", tc.name, err, tc.input)
+		}
+	}
+
+	// A negative limit disables the check.
+	c.opts = Options{MaxCertificateBytes: -1}
+	if err := c.CheckConnectionState(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}); err != nil {
+		t.Errorf("CheckConnectionState without a certificate limit: %v", err)
+	}
+}
+
+func TestAcceptPendingLogsRecorded(t *testing.T) {
+	l := newTestLog(t, "Pending Log")
+	l.log.State = &loglist2.LogStates{Pending: &loglist2.LogState{Timestamp: time.Now().Add(-time.Hour)}}
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}}
+
+	for _, accept := range []bool{false, true} {
+		c := newTestChecker(l)
+		c.opts.AcceptPendingLogs = accept
+		result, err := c.CheckConnectionStateDetailed(state)
+		if err != nil {
+			t.Fatalf("CheckConnectionStateDetailed: %v", err)
+		}
+		if len(result.SCTs) != 1 {
+			t.Fatalf("got %d SCT results, want 1", len(result.SCTs))
+		}
+		s := result.SCTs[0]
+		if s.LogStatus != loglist2.PendingLogStatus {
+			t.Errorf("AcceptPendingLogs %v: LogStatus = %v, want pending", accept, s.LogStatus)
+		}
+		if s.Valid() != accept || (accept && len(s.Warnings) != 1) {
+			t.Errorf("AcceptPendingLogs %v: valid %v with warnings %v, error %v", accept, s.Valid(), s.Warnings, s.Err)
+		}
+	}
+}
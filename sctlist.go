@@ -0,0 +1,73 @@
+package sct
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+)
+
+// CheckSCTListBytes verifies a TLS-encoded SCT list using the default checker.
+// See (*checker).CheckSCTListBytes.
+func CheckSCTListBytes(listBytes []byte, chain []*ctx509.Certificate, entryType ct.LogEntryType) (*Result, error) {
+	return GetDefaultChecker().CheckSCTListBytes(listBytes, chain, entryType)
+}
+
+// CheckSCTListBytes verifies each SCT in listBytes, a TLS-encoded SignedCertificateTimestampList
+// (RFC 6962 s3.3) as carried in the certificate extension (once unwrapped from its OCTET STRING),
+// the TLS extension or an OCSP response, for the leaf chain[0].
+//
+// entryType selects what the SCTs were signed over: ct.PrecertLogEntryType for SCTs embedded in
+// the leaf, which needs the leaf's issuer, or ct.X509LogEntryType for SCTs delivered alongside it.
+// The results' Source is SourceEmbedded or SourceTLSExtension accordingly. The SCTs are verified
+// as CheckConnectionStateDetailed verifies embedded and TLS SCTs respectively.
+func (c *checker) CheckSCTListBytes(listBytes []byte, chain []*ctx509.Certificate, entryType ct.LogEntryType) (*Result, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("no certificates in chain")
+	}
+
+	if err := c.checkSCTListSize("SCT list", len(listBytes)); err != nil {
+		return nil, err
+	}
+
+	raw, err := parseSCTList(listBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("no SCTs in SCT list")
+	}
+
+	result := &Result{
+		Validity:   certValidity(chain[0], c.opts.now()),
+		ShortLived: IsShortLived(chain[0]),
+	}
+	switch entryType {
+	case ct.X509LogEntryType:
+		result.SCTs = c.verifyDeliveredSCTs(nil, raw, chain, SourceTLSExtension)
+	case ct.PrecertLogEntryType:
+		result.SCTs = c.verifyEmbeddedSCTList(nil, serializedSCTs(raw), chain[0], c.issuerFor(context.Background(), chain))
+	default:
+		return nil, fmt.Errorf("unsupported log entry type %v", entryType)
+	}
+	setIssuanceDelays(result.SCTs, chain[0])
+	return result, nil
+}
+
+// SCTFingerprint returns a stable identifier for a serialized SCT, for deduplicating and joining
+// datasets collected independently: the SHA-256 hash of the SCT's canonical TLS encoding, as
+// re-encoded from its decoded fields (version, log ID, timestamp, extensions and signature).
+// An SCT which cannot be decoded is fingerprinted by its bytes as given.
+func SCTFingerprint(serialized []byte) [sha256.Size]byte {
+	var sct ct.SignedCertificateTimestamp
+	if rest, err := cttls.Unmarshal(serialized, &sct); err == nil && len(rest) == 0 {
+		if canonical, err := cttls.Marshal(sct); err == nil {
+			return sha256.Sum256(canonical)
+		}
+	}
+	return sha256.Sum256(serialized)
+}
@@ -0,0 +1,112 @@
+package sct
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRequestBytes bounds the body of a request to Handler.
+const maxRequestBytes = 1 << 20
+
+// checkRequest is the JSON body of a POST to Handler's /check endpoint.
+type checkRequest struct {
+	// Chain holds the PEM certificates presented by the server, in any order.
+	Chain string `json:"chain"`
+	// SCTs holds the base64 SCTs of the TLS extension, if any.
+	SCTs [][]byte `json:"scts,omitempty"`
+	// OCSPResponse is the base64 DER stapled OCSP response, if any.
+	OCSPResponse []byte `json:"ocsp_response,omitempty"`
+}
+
+// Handler returns an http.Handler serving the default checker. See (*checker).Handler.
+func Handler() http.Handler {
+	return GetDefaultChecker().Handler()
+}
+
+// Handler returns an http.Handler exposing the checker to other services, e.g. as a sidecar.
+// It serves two endpoints, both accepting POST only:
+//
+//   - /check verifies the connection data in a JSON checkRequest body, as CheckConnectionStateDetailed
+//     would, and responds with a JSON object in the format written by CheckHosts, without the host.
+//     A request which cannot be checked at all gets a 400 response with status HostCheckError.
+//   - /refresh fetches the log list again, see RefreshLogList, and responds 204 on success, or 409
+//     if the checker's log list is pinned.
+//
+// The handler does no authentication: /refresh in particular should not be exposed to untrusted clients.
+func (c *checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check", c.serveCheck)
+	mux.HandleFunc("/refresh", c.serveRefresh)
+	return mux
+}
+
+func (c *checker) serveCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, code := c.check(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+// check checks the connection data in the body of r, and returns the response and its status code.
+func (c *checker) check(r *http.Request) (*hostResult, int) {
+	res := &hostResult{}
+
+	var req checkRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		res.Status = HostCheckError
+		res.Error = fmt.Sprintf("malformed request: %v", err)
+		return res, http.StatusBadRequest
+	}
+
+	certs, err := parsePEMCertificates([]byte(req.Chain))
+	if err != nil {
+		res.Status = HostCheckError
+		res.Error = err.Error()
+		return res, http.StatusBadRequest
+	}
+
+	result, err := c.CheckConnectionStateDetailedContext(r.Context(), &tls.ConnectionState{
+		PeerCertificates:            orderChain(certs),
+		SignedCertificateTimestamps: req.SCTs,
+		OCSPResponse:                req.OCSPResponse,
+	})
+	if result == nil {
+		res.Status = HostCheckError
+		res.Error = err.Error()
+		return res, http.StatusBadRequest
+	}
+	res.setResult(result)
+	if err != nil {
+		// Interrupted: report the SCTs verified so far.
+		res.setInterrupted(err)
+	}
+	return res, http.StatusOK
+}
+
+func (c *checker) serveRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := c.RefreshLogList(); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrPinnedLogList) {
+			status = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf("failed to refresh log list: %v", err), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package sct
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerCheck(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issue(t, leafTemplate())
+	tlsSCT := marshalSCT(t, l.sign(t, x509Leaf(t, mustBuildChain(t, leaf, ca.cert)), time.Now()))
+	srv := httptest.NewServer(newTestChecker(l).Handler())
+	defer srv.Close()
+
+	body, err := json.Marshal(checkRequest{
+		Chain: string(pem.EncodeToMemory(certBlock(leaf))) + string(pem.EncodeToMemory(certBlock(ca.cert))),
+		SCTs:  [][]byte{tlsSCT},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(srv.URL+"/check", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var res hostResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != HostValid || len(res.SCTs) != 1 || res.SCTs[0].Source != SourceTLSExtension.String() {
+		t.Errorf("got %+v, want one valid SCT from the TLS extension", res)
+	}
+
+	resp, err = http.Post(srv.URL+"/check", "application/json", bytes.NewReader([]byte(`{"chain": ""}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status without certificates = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, err = http.Get(srv.URL + "/check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlerCheckInterrupted(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	// The log never answers, so the check is interrupted while proving inclusion.
+	srv := serveSTH(t, l.signSTH(t, 1, make([]byte, 32)), time.Minute)
+	defer srv.Close()
+	l.log.URL = srv.URL
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	c := newTestChecker(l)
+	c.opts.RequireInclusion = true
+
+	body, err := json.Marshal(checkRequest{
+		Chain: string(pem.EncodeToMemory(certBlock(leaf))) + string(pem.EncodeToMemory(certBlock(ca.cert))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodPost, "/check", bytes.NewReader(body)).WithContext(ctx)
+
+	res, status := c.check(r)
+	if status != http.StatusOK || res.Status != HostCheckError || res.Error == "" {
+		t.Errorf("interrupted check: status %d, %q, error %q; want %d, %q with the interruption",
+			status, res.Status, res.Error, http.StatusOK, HostCheckError)
+	}
+}
@@ -0,0 +1,88 @@
+package sct
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ResultStore persists the results of checks, see Options.ResultStore. Save may be called
+// concurrently.
+type ResultStore interface {
+	Save(host string, r *Result) error
+}
+
+// MemoryStore is a ResultStore keeping every result in memory. The zero value is ready to use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	results map[string][]*Result
+}
+
+// Save appends r to the results of host.
+func (s *MemoryStore) Save(host string, r *Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results == nil {
+		s.results = make(map[string][]*Result)
+	}
+	s.results[host] = append(s.results[host], r)
+	return nil
+}
+
+// Results returns the results saved for host, oldest first.
+func (s *MemoryStore) Results(host string) []*Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Result(nil), s.results[host]...)
+}
+
+// Hosts returns the number of hosts with saved results.
+func (s *MemoryStore) Hosts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.results)
+}
+
+// JSONFileStore is a ResultStore appending each result to a file as a JSON line, in the format
+// written by CheckHosts with an added saved_at time.
+type JSONFileStore struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// storedResult is a line of a JSONFileStore.
+type storedResult struct {
+	SavedAt time.Time `json:"saved_at"`
+	hostResult
+}
+
+// NewJSONFileStore returns a JSONFileStore appending to the file at path, which is created if
+// needed. It must be closed after use.
+func NewJSONFileStore(path string) (*JSONFileStore, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONFileStore{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Save appends r, as checked for host, to the file.
+func (s *JSONFileStore) Save(host string, r *Result) error {
+	stored := storedResult{SavedAt: time.Now().UTC(), hostResult: hostResult{Host: host}}
+	stored.setResult(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(&stored); err != nil {
+		return fmt.Errorf("failed to save result for %s: %v", host, err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *JSONFileStore) Close() error {
+	return s.f.Close()
+}
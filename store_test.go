@@ -0,0 +1,85 @@
+package sct
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	store := &MemoryStore{}
+	c := newTestChecker(l)
+	c.opts.ResultStore = store
+
+	states := map[string]*tls.ConnectionState{
+		"a.example.com": {PeerCertificates: []*x509.Certificate{leaf, ca.cert}},
+		"b.example.com": nil,
+	}
+	for i := 0; i < 2; i++ {
+		c.CheckConnectionStates(context.Background(), states)
+	}
+
+	if n := store.Hosts(); n != 1 {
+		t.Errorf("results saved for %d hosts, want only the checked one", n)
+	}
+	results := store.Results("a.example.com")
+	if len(results) != 2 || results[0].ValidCount() != 1 {
+		t.Errorf("got %d saved results, want 2 with a valid SCT", len(results))
+	}
+}
+
+func TestJSONFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zsct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.jsonl")
+
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+	result, err := newTestChecker(l).CheckConnectionStateDetailed(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca.cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening the file appends to it.
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		store, err := NewJSONFileStore(path)
+		if err != nil {
+			t.Fatalf("NewJSONFileStore: %v", err)
+		}
+		if err := store.Save(host, result); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var stored storedResult
+	if err := json.Unmarshal([]byte(lines[1]), &stored); err != nil {
+		t.Fatalf("failed to parse %q: %v", lines[1], err)
+	}
+	if stored.SavedAt.IsZero() || stored.Host != "b.example.com" || stored.Status != HostValid || len(stored.SCTs) != 1 {
+		t.Errorf("got %s, want b.example.com's valid result with its save time", lines[1])
+	}
+}
@@ -0,0 +1,29 @@
+package sct
+
+import (
+	"net/http"
+)
+
+// version is reported in the default User-Agent.
+const version = "0.1.0"
+
+const defaultUserAgent = "zsct/" + version
+
+// userAgentTransport sets the User-Agent header on each request before passing it on.
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient returns an HTTP client sending userAgent with every request.
+func newHTTPClient(userAgent string) *http.Client {
+	return &http.Client{
+		Transport: &userAgentTransport{userAgent: userAgent, base: http.DefaultTransport},
+	}
+}
@@ -1,19 +1,26 @@
 package sct
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
 
+	"github.com/google/certificate-transparency-go/asn1"
 	ctx509 "github.com/google/certificate-transparency-go/x509"
+	ctx509util "github.com/google/certificate-transparency-go/x509util"
 )
 
 func BuildCertificateChain(certs []*x509.Certificate) ([]*ctx509.Certificate, error) {
 	chain := make([]*ctx509.Certificate, len(certs))
 
 	for i, cert := range certs {
-		newCert, err := ctx509.ParseCertificate(cert.Raw)
+		newCert, err := ConvertCert(cert)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+			return nil, err
 		}
 
 		chain[i] = newCert
@@ -21,3 +28,208 @@ func BuildCertificateChain(certs []*x509.Certificate) ([]*ctx509.Certificate, er
 
 	return chain, nil
 }
+
+// ConvertCert re-parses c's DER encoding with the CT x509 parser, so that the embedded SCT list
+// and precertificate poison extensions are decoded. Copying fields from the crypto/x509
+// certificate instead would lose them. Non-fatal parse errors, such as a malformed SCT list
+// extension, are reported by the checks as warnings rather than failing the conversion.
+func ConvertCert(c *x509.Certificate) (*ctx509.Certificate, error) {
+	if c == nil || len(c.Raw) == 0 {
+		return nil, errors.New("failed to parse certificate: no DER encoding")
+	}
+	cert, err := parseCertificate(c.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// parseCertificate parses a DER certificate like ctx509.ParseCertificate, but also returns the
+// certificates which only have non-fatal errors, see parseWarnings.
+func parseCertificate(der []byte) (*ctx509.Certificate, error) {
+	cert, err := ctx509.ParseCertificate(der)
+	if ctx509.IsFatal(err) {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// parseWarnings returns the non-fatal errors of parsing cert, which parseCertificate ignores.
+func parseWarnings(cert *ctx509.Certificate) []string {
+	_, err := ctx509.ParseCertificate(cert.Raw)
+	var nfe ctx509.NonFatalErrors
+	if !errors.As(err, &nfe) {
+		return nil
+	}
+	var warnings []string
+	for _, err := range nfe.Errors {
+		warnings = append(warnings, fmt.Sprintf("certificate parsed with errors: %v", err))
+	}
+	return warnings
+}
+
+// readPEMCertificate parses the first certificate in a PEM file, skipping any other blocks.
+func readPEMCertificate(path string) (*ctx509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM certificate found in %s", path)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := parseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in %s: %v", path, err)
+		}
+		return cert, nil
+	}
+}
+
+// checkIssuer returns an error if issuer is not the issuer named by leaf.
+func checkIssuer(leaf, issuer *ctx509.Certificate) error {
+	if !bytes.Equal(leaf.RawIssuer, issuer.RawSubject) {
+		return fmt.Errorf("issuer certificate %q does not match leaf issuer %q", issuer.Subject, leaf.Issuer)
+	}
+
+	if len(leaf.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 && !bytes.Equal(leaf.AuthorityKeyId, issuer.SubjectKeyId) {
+		return errors.New("issuer certificate key ID does not match leaf authority key ID")
+	}
+
+	return nil
+}
+
+// checkIssuerSignature returns an error if Options.VerifyIssuerSignature is set and leaf does
+// not carry a valid signature by issuer.
+func (c *checker) checkIssuerSignature(leaf, issuer *ctx509.Certificate) error {
+	if !c.opts.VerifyIssuerSignature {
+		return nil
+	}
+	if err := leaf.CheckSignatureFrom(issuer); err != nil {
+		return fmt.Errorf("leaf certificate is not signed by issuer %q: %v", issuer.Subject, err)
+	}
+	return nil
+}
+
+// checkEmbeddedSCTCount re-parses the leaf's raw SCT list extension independently of the x509
+// parser, and returns an error if the number of SCTs it holds differs from leaf.SCTList, or if it
+// is malformed. This catches corrupted extensions which would otherwise look like fewer SCTs.
+func checkEmbeddedSCTCount(leaf *ctx509.Certificate) error {
+	var value []byte
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(ctx509.OIDExtensionCTSCT) {
+			value = ext.Value
+			break
+		}
+	}
+	if value == nil {
+		if n := len(leaf.SCTList.SCTList); n != 0 {
+			return fmt.Errorf("certificate has %d parsed SCTs but no SCT list extension", n)
+		}
+		return nil
+	}
+
+	var raw []byte
+	if rest, err := asn1.Unmarshal(value, &raw); err != nil {
+		return fmt.Errorf("malformed SCT list extension: %v", err)
+	} else if len(rest) > 0 {
+		return fmt.Errorf("malformed SCT list extension: %d trailing bytes", len(rest))
+	}
+
+	// SignedCertificateTimestampList: a uint16 length-prefixed list of uint16 length-prefixed SCTs.
+	if len(raw) < 2 {
+		return errors.New("malformed SCT list extension: truncated list length")
+	}
+	listLen := int(raw[0])<<8 | int(raw[1])
+	list := raw[2:]
+	if listLen != len(list) {
+		return fmt.Errorf("malformed SCT list extension: list length %d, but %d bytes present", listLen, len(list))
+	}
+
+	count := 0
+	for len(list) > 0 {
+		if len(list) < 2 {
+			return fmt.Errorf("malformed SCT list extension: truncated length of SCT %d", count)
+		}
+		sctLen := int(list[0])<<8 | int(list[1])
+		if sctLen == 0 || sctLen > len(list)-2 {
+			return fmt.Errorf("malformed SCT list extension: bad length %d for SCT %d", sctLen, count)
+		}
+		list = list[2+sctLen:]
+		count++
+	}
+
+	if n := len(leaf.SCTList.SCTList); n != count {
+		return fmt.Errorf("SCT list extension holds %d SCTs, but %d were parsed", count, n)
+	}
+
+	return nil
+}
+
+// checkSCTExtensionEncoding returns warnings about how leaf carries its SCT list extension: it
+// must appear at most once and not be critical (RFC 6962 s3.3), and SCTs must not be put in the
+// extension defined for OCSP responses instead. The list's own encoding is checked by
+// checkEmbeddedSCTCount.
+func checkSCTExtensionEncoding(leaf *ctx509.Certificate) []string {
+	var warnings []string
+	count := 0
+	for _, ext := range leaf.Extensions {
+		switch {
+		case ext.Id.Equal(ctx509.OIDExtensionCTSCT):
+			count++
+			if ext.Critical {
+				warnings = append(warnings, "SCT list extension is marked critical")
+			}
+		case ext.Id.Equal(asn1.ObjectIdentifier(oidExtensionCTOCSP)):
+			warnings = append(warnings, fmt.Sprintf("certificate has the OCSP SCT list extension %v, SCTs belong in %v",
+				oidExtensionCTOCSP, ctx509.OIDExtensionCTSCT))
+		}
+	}
+	if count > 1 {
+		warnings = append(warnings, fmt.Sprintf("SCT list extension appears %d times", count))
+	}
+
+	return warnings
+}
+
+// duplicateSCTLogs returns the KeyIDs of logs with more than one SCT embedded in leaf, in order
+// of first appearance. A CA should submit a certificate to each log only once, so this hints at
+// a CA submission bug. SCTs which cannot be parsed are ignored.
+func duplicateSCTLogs(leaf *ctx509.Certificate) [][sha256.Size]byte {
+	counts := make(map[[sha256.Size]byte]int)
+	var dups [][sha256.Size]byte
+	for i := range leaf.SCTList.SCTList {
+		sct, err := ctx509util.ExtractSCT(&leaf.SCTList.SCTList[i])
+		if err != nil {
+			continue
+		}
+		counts[sct.LogID.KeyID]++
+		if counts[sct.LogID.KeyID] == 2 {
+			dups = append(dups, sct.LogID.KeyID)
+		}
+	}
+
+	return dups
+}
+
+// embeddedSCTWarnings returns the certificate-level warnings about leaf and the SCTs embedded in
+// it.
+func embeddedSCTWarnings(leaf *ctx509.Certificate) []string {
+	warnings := parseWarnings(leaf)
+	warnings = append(warnings, checkSCTExtensionEncoding(leaf)...)
+	if err := checkEmbeddedSCTCount(leaf); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	for _, keyID := range duplicateSCTLogs(leaf) {
+		warnings = append(warnings, fmt.Sprintf("multiple embedded SCTs from log with KeyID %x", keyID))
+	}
+
+	return warnings
+}
@@ -0,0 +1,189 @@
+package sct
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	x509pkix "crypto/x509/pkix"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+func TestCheckEmbeddedSCTCount(t *testing.T) {
+	logs := []*testLog{newTestLog(t, "Log A"), newTestLog(t, "Log B")}
+	ca := newTestCA(t)
+	withSCTs := mustBuildChain(t, ca.issueWithEmbeddedSCTs(t, leafTemplate(), logs...))[0]
+	withoutSCTs := mustBuildChain(t, ca.issue(t, leafTemplate()))[0]
+
+	if err := checkEmbeddedSCTCount(withSCTs); err != nil {
+		t.Errorf("well-formed SCT list: %v", err)
+	}
+	if err := checkEmbeddedSCTCount(withoutSCTs); err != nil {
+		t.Errorf("no SCT list: %v", err)
+	}
+
+	undercounted := *withSCTs
+	undercounted.SCTList.SCTList = undercounted.SCTList.SCTList[:1]
+	if err := checkEmbeddedSCTCount(&undercounted); err == nil || !strings.Contains(err.Error(), "holds 2 SCTs, but 1 were parsed") {
+		t.Errorf("undercounted SCT list: got %v", err)
+	}
+
+	// A list whose second SCT claims more bytes than remain.
+	raw := []byte{0x00, 0x07, 0x00, 0x01, 0xaa, 0x00, 0x09, 0xbb, 0xcc}
+	value, err := asn1.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := *withoutSCTs
+	corrupted.Extensions = append(corrupted.Extensions, pkix.Extension{Id: ctx509.OIDExtensionCTSCT, Value: value})
+	if err := checkEmbeddedSCTCount(&corrupted); err == nil || !strings.Contains(err.Error(), "bad length 9 for SCT 1") {
+		t.Errorf("corrupted SCT list: got %v", err)
+	}
+}
+
+func TestMalformedSCTListDER(t *testing.T) {
+	// The same corrupted list as above, in a certificate issued with it.
+	value, err := asn1.Marshal([]byte{0x00, 0x07, 0x00, 0x01, 0xaa, 0x00, 0x09, 0xbb, 0xcc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	tmpl.ExtraExtensions = []x509pkix.Extension{{Id: []int(testOIDSCTList), Value: value}}
+	leaf := ca.issue(t, tmpl)
+
+	if _, err := ConvertCert(leaf); err != nil {
+		t.Fatalf("ConvertCert failed on a non-fatal parse error: %v", err)
+	}
+	result, err := newTestChecker(newTestLog(t, "Test Log")).CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	warnings := strings.Join(result.Warnings, "\n")
+	if !strings.Contains(warnings, "certificate parsed with errors") || !strings.Contains(warnings, "bad length 9 for SCT 1") {
+		t.Errorf("warnings = %q, want the parse error and the malformed SCT list reported", result.Warnings)
+	}
+}
+
+func TestCheckSCTExtensionEncoding(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := mustBuildChain(t, ca.issueWithEmbeddedSCTs(t, leafTemplate(), newTestLog(t, "Test Log")))[0]
+	if warnings := checkSCTExtensionEncoding(leaf); len(warnings) != 0 {
+		t.Errorf("well-formed SCT list extension: got warnings %q", warnings)
+	}
+
+	var sctExt pkix.Extension
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(ctx509.OIDExtensionCTSCT) {
+			sctExt = ext
+		}
+	}
+	critical := sctExt
+	critical.Critical = true
+	misplaced := sctExt
+	misplaced.Id = asn1.ObjectIdentifier(oidExtensionCTOCSP)
+
+	for _, tc := range []struct {
+		name string
+		exts []pkix.Extension
+		want string
+	}{
+		{"critical", []pkix.Extension{critical}, "marked critical"},
+		{"repeated", []pkix.Extension{sctExt, sctExt}, "appears 2 times"},
+		{"OCSP OID", []pkix.Extension{misplaced}, "OCSP SCT list extension"},
+	} {
+		cert := *leaf
+		cert.Extensions = tc.exts
+		warnings := checkSCTExtensionEncoding(&cert)
+		if len(warnings) != 1 || !strings.Contains(warnings[0], tc.want) {
+			t.Errorf("%s: warnings = %q, want one containing %q", tc.name, warnings, tc.want)
+		}
+	}
+}
+
+func TestDuplicateSCTLogWarning(t *testing.T) {
+	dup := newTestLog(t, "Duplicated Log")
+	other := newTestLog(t, "Other Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+	leaf := ca.issueWithEmbeddedSCTs(t, tmpl, dup, other, dup)
+	c := newTestChecker(dup, other)
+
+	result, err := c.CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], fmt.Sprintf("KeyID %x", dup.log.LogID)) {
+		t.Errorf("warnings = %q, want one about the duplicated log", result.Warnings)
+	}
+
+	unique := mustBuildChain(t, ca.issueWithEmbeddedSCTs(t, tmpl, dup, other))[0]
+	if dups := duplicateSCTLogs(unique); len(dups) != 0 {
+		t.Errorf("duplicateSCTLogs reported %x for distinct logs", dups)
+	}
+}
+
+func TestConvertCert(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	tmpl := leafTemplate()
+
+	leaf, err := ConvertCert(ca.issueWithEmbeddedSCTs(t, tmpl, l))
+	if err != nil {
+		t.Fatalf("ConvertCert(leaf): %v", err)
+	}
+	if n := len(leaf.SCTList.SCTList); n != 1 {
+		t.Errorf("converted leaf has %d SCTs, want 1", n)
+	}
+
+	precert, err := ConvertCert(ca.issuePrecert(t, tmpl))
+	if err != nil {
+		t.Fatalf("ConvertCert(precert): %v", err)
+	}
+	if !precert.IsPrecertificate() {
+		t.Error("converted precertificate lost its poison extension")
+	}
+
+	if _, err := ConvertCert(&x509.Certificate{}); err == nil {
+		t.Error("ConvertCert accepted a certificate without DER")
+	}
+}
+
+func TestEd25519Leaf(t *testing.T) {
+	l := newTestLog(t, "Test Log")
+	ca := newTestCA(t)
+	_, leafKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.leafKey = leafKey
+	leaf := ca.issueWithEmbeddedSCTs(t, leafTemplate(), l)
+
+	chain, err := BuildCertificateChain([]*x509.Certificate{leaf, ca.cert})
+	if err != nil {
+		t.Fatalf("BuildCertificateChain: %v", err)
+	}
+	if chain[0].PublicKeyAlgorithm != ctx509.Ed25519 {
+		t.Errorf("leaf public key algorithm = %v, want Ed25519", chain[0].PublicKeyAlgorithm)
+	}
+
+	result, err := newTestChecker(l).CheckConnectionStateDetailed(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca.cert},
+	})
+	if err != nil {
+		t.Fatalf("CheckConnectionStateDetailed: %v", err)
+	}
+	if len(result.SCTs) != 1 || result.ValidCount() != 1 {
+		t.Errorf("got %d SCTs, %d valid; want 1 valid", len(result.SCTs), result.ValidCount())
+	}
+}